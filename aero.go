@@ -2,36 +2,60 @@ package aero
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/dhamith93/aero/internal/api"
 	"github.com/dhamith93/aero/internal/auth"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
+const defaultTimeout = 10 * time.Second
+
 type Aero struct {
-	key          string
-	Devices      []Device
-	Self         *Device
-	Server       api.Server
-	SocketServer SocketServer
-	grpcServer   *grpc.Server
-	Listener     chan bool
-	IsMaster     bool
+	key           string
+	registry      *registry
+	Server        *api.Server
+	SocketServer  *SocketServer
+	grpcServer    *grpc.Server
+	grpcMu        *sync.Mutex
+	configMu      *sync.RWMutex
+	serverTLS     *tls.Config
+	clientTLS     *tls.Config
+	discoveryAddr string
+	heartbeat     heartbeatConfig
+	offerHandler  OfferHandler
+	bandwidth     *bandwidth
+	logger        *logger
+	timeout       time.Duration
+	pool          *connPool
+	health        *health.Server
 }
 
 func New(device Device, isMaster bool) Aero {
 	aero := Aero{}
-	aero.Devices = append(aero.Devices, device)
-	aero.Self = &aero.Devices[0]
-	aero.IsMaster = isMaster
-	aero.Listener = make(chan bool)
+	aero.registry = newRegistry(device, isMaster)
+	aero.Server = &api.Server{}
+	aero.bandwidth = &bandwidth{}
+	aero.logger = &logger{messages: &AeroMessages{}}
+	aero.timeout = defaultTimeout
+	aero.pool = &connPool{}
+	aero.grpcMu = &sync.Mutex{}
+	aero.configMu = &sync.RWMutex{}
+	aero.registry.replaced = aero.pool.retain
+	aero.registry.departed = aero.deviceDeparted
+	aero.SocketServer = &SocketServer{Messages: aero.logger.messages, logger: aero.logger, registry: aero.registry, bandwidth: aero.bandwidth}
 	return aero
 }
 
@@ -43,127 +67,295 @@ func (aero *Aero) StartGrpcServer() error {
 	if len(aero.key) == 0 {
 		return fmt.Errorf("auth key is not set")
 	}
-	aero.Server = api.Server{IsMaster: aero.IsMaster, Listener: &aero.Listener}
-	aero.Server.Devices = append(aero.Server.Devices, GenerateAPIDeviceFromDevice(aero.Self))
-	aero.Server.Self = aero.Server.Devices[0]
-	aero.grpcServer = grpc.NewServer(grpc.UnaryInterceptor(aero.authInterceptor))
-	api.RegisterServiceServer(aero.grpcServer, &aero.Server)
-	lis, err := net.Listen("tcp", ":"+aero.Self.Port)
+	if err := aero.ensureIdentity(); err != nil {
+		return err
+	}
+	self, _ := aero.registry.updateSelf(func(self *Device) error {
+		self.Active = true
+		return nil
+	})
+	aero.Server.SetMaster(aero.IsMaster())
+	aero.Server.Manifest = aero.manifest
+	aero.Server.Ticket = aero.generateTicket
+	aero.Server.Accept = aero.acceptOffer
+	aero.Server.Log = aero.logServer
+	aero.Server.Compression = aero.SocketServer.compressionEnabled
+	aero.Server.SetSelf(GenerateAPIDeviceFromDevice(&self))
+	aero.Server.SetDevices([]*api.Device{GenerateAPIDeviceFromDevice(&self)})
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(aero.authInterceptor), grpc.StreamInterceptor(aero.streamAuthInterceptor)}
+	opts = append(opts, serverKeepalive()...)
+	if aero.serverTLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(aero.serverTLS)))
+	}
+	grpcServer := grpc.NewServer(opts...)
+	healthServer := health.NewServer()
+	api.RegisterServiceServer(grpcServer, aero.Server)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	lis, err := net.Listen("tcp", ":"+self.Port)
 	if err != nil {
+		aero.logger.error("grpc", "cannot listen on port "+self.Port, logErr(err))
 		return err
 	}
+	aero.grpcMu.Lock()
+	aero.grpcServer = grpcServer
+	aero.health = healthServer
+	aero.grpcMu.Unlock()
 	go aero.listenForDeviceChanges()
-	return aero.grpcServer.Serve(lis)
+	aero.logger.info("grpc", "listening on port "+self.Port)
+	err = grpcServer.Serve(lis)
+	if err != nil {
+		aero.logger.error("grpc", "server stopped", logErr(err))
+	}
+	return err
 }
 
 func (aero *Aero) StartSocketServer() error {
 	if len(aero.key) == 0 {
 		return fmt.Errorf("auth key is not set")
 	}
-	aero.SocketServer = SocketServer{Port: aero.Server.Self.SocketPort, Devices: &aero.Devices, Self: aero.Self, Messages: &AeroMessages{}}
-	return aero.SocketServer.Start()
+	port := aero.registry.getSelf().SocketPort
+	aero.SocketServer.configure(port, aero.key, aero.getChunks, aero.fetchTicket, aero.serverTLS, aero.clientTLS)
+	aero.logger.info("socket", "listening on port "+port)
+	err := aero.SocketServer.Start()
+	if err != nil {
+		aero.logger.error("socket", "server stopped", logErr(err))
+	}
+	return err
 }
 
 func (aero *Aero) Stop() {
-	aero.grpcServer.Stop()
+	// an already cancelled context stops right away, draining is left to Shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	aero.stopGrpcServer(ctx)
 	aero.SocketServer.Stop()
+	aero.pool.close()
+}
+
+// in-flight calls finish unless ctx ends first
+func (aero *Aero) stopGrpcServer(ctx context.Context) {
+	aero.grpcMu.Lock()
+	defer aero.grpcMu.Unlock()
+	if aero.grpcServer == nil {
+		return
+	}
+	aero.Server.CloseWatchers()
+	done := make(chan struct{})
+	go func() {
+		aero.grpcServer.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		aero.grpcServer.Stop()
+		<-done
+	}
 }
 
 func (aero *Aero) listenForDeviceChanges() {
-	for v := range aero.Listener {
-		if v {
-			out := []Device{}
-			for _, d := range aero.Server.Devices {
-				out = append(out, *GenerateDeviceFromAPIDevice(d))
-			}
-			aero.Devices = out
+	for range aero.Server.Changes() {
+		if !aero.IsMaster() {
+			continue
+		}
+		out := []Device{}
+		for _, d := range aero.Server.Snapshot() {
+			out = append(out, *GenerateDeviceFromAPIDevice(d))
 		}
+		aero.registry.replace(out)
 	}
-	aero.Listener <- true
+}
+
+func (aero *Aero) SetTimeout(timeout time.Duration) {
+	aero.configMu.Lock()
+	aero.timeout = timeout
+	aero.configMu.Unlock()
 }
 
 func (aero *Aero) AddFile(f File) error {
-	for _, file := range aero.Self.Files {
-		if f.Hash == file.Hash {
-			return fmt.Errorf("file with same hash exists")
+	return aero.AddFileContext(context.Background(), f)
+}
+
+func (aero *Aero) AddFileContext(ctx context.Context, f File) error {
+	self, err := aero.registry.updateSelf(func(self *Device) error {
+		for _, file := range self.Files {
+			if f.Hash == file.Hash && f.RelPath == file.RelPath {
+				return fmt.Errorf("file with same hash exists")
+			}
 		}
+		self.Files = append(self.Files, f)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-	aero.Self.Files = append(aero.Self.Files, f)
-	aero.SendRefresh(*aero.Self)
+	aero.SendRefreshContext(ctx, self)
 	return nil
 }
 
+func (aero *Aero) AddDirectory(path string) error {
+	return aero.AddDirectoryContext(context.Background(), path)
+}
+
+func (aero *Aero) AddDirectoryContext(ctx context.Context, path string) error {
+	files, err := NewDirectory(path)
+	if err != nil {
+		return err
+	}
+	self, _ := aero.registry.updateSelf(func(self *Device) error {
+		for _, f := range files {
+			exists := false
+			for _, file := range self.Files {
+				if f.Hash == file.Hash && f.RelPath == file.RelPath {
+					exists = true
+				}
+			}
+			if !exists {
+				self.Files = append(self.Files, f)
+			}
+		}
+		return nil
+	})
+	_, err = aero.SendRefreshContext(ctx, self)
+	return err
+}
+
 func (aero *Aero) RemoveFileAt(fileIdx int) error {
-	if fileIdx < 0 && len(aero.Self.Files) >= fileIdx {
-		return fmt.Errorf("file index out of bound")
+	return aero.RemoveFileAtContext(context.Background(), fileIdx)
+}
+
+func (aero *Aero) RemoveFileAtContext(ctx context.Context, fileIdx int) error {
+	self, err := aero.registry.updateSelf(func(self *Device) error {
+		if fileIdx < 0 || fileIdx >= len(self.Files) {
+			return fmt.Errorf("file index out of bound")
+		}
+		self.Files[fileIdx] = self.Files[len(self.Files)-1]
+		self.Files = self.Files[:len(self.Files)-1]
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-	aero.Self.Files[fileIdx] = aero.Self.Files[len(aero.Self.Files)-1]
-	aero.Self.Files[len(aero.Self.Files)-1] = File{}
-	aero.Self.Files = aero.Self.Files[:len(aero.Self.Files)-1]
-	aero.SendRefresh(*aero.Self)
+	aero.SendRefreshContext(ctx, self)
 	return nil
 }
 
 func (aero *Aero) SendInit(d Device, master Device) ([]Device, error) {
-	aero.Self = &d
+	return aero.SendInitContext(context.Background(), d, master)
+}
+
+func (aero *Aero) SendInitContext(ctx context.Context, d Device, master Device) ([]Device, error) {
+	aero.adoptSelf(d)
+	if err := aero.ensureIdentity(); err != nil {
+		return nil, err
+	}
+	d = aero.registry.getSelf()
 	device := GenerateAPIDeviceFromDevice(&d)
-	aero.Server.Self = device
-	return aero.initDevice(device, master)
+	aero.Server.SetSelf(device)
+	return aero.initDevice(ctx, device, master)
 }
 
 func (aero *Aero) SendRefresh(d Device) (Device, error) {
-	aero.Self = &d
+	return aero.SendRefreshContext(context.Background(), d)
+}
+
+func (aero *Aero) SendRefreshContext(ctx context.Context, d Device) (Device, error) {
+	d = aero.adoptSelf(d)
 	device := GenerateAPIDeviceFromDevice(&d)
-	aero.Server.Self = device
-	return aero.refreshDevice(device)
+	aero.Server.SetSelf(device)
+	return aero.refreshDevice(ctx, device)
 }
 
 func (aero *Aero) GetList() ([]Device, error) {
-	return aero.getList()
+	return aero.GetListContext(context.Background())
+}
+
+func (aero *Aero) GetListContext(ctx context.Context) ([]Device, error) {
+	return aero.getList(ctx)
 }
 
 func (aero *Aero) GetStatus(d Device) (Device, error) {
-	return aero.getStatus(d)
+	return aero.GetStatusContext(context.Background(), d)
+}
+
+func (aero *Aero) GetStatusContext(ctx context.Context, d Device) (Device, error) {
+	return aero.getStatus(ctx, d)
 }
 
 func (aero *Aero) FetchFile(d Device, fileIdx int) error {
-	return aero.fetchFile(d, fileIdx)
+	return aero.FetchFileContext(context.Background(), d, fileIdx)
+}
+
+func (aero *Aero) FetchFileContext(ctx context.Context, d Device, fileIdx int) error {
+	if fileIdx < 0 || fileIdx >= len(d.Files) {
+		return fmt.Errorf("file doesn't exists in the device")
+	}
+	_, _, err := aero.fetchTicket(ctx, d, d.Files[fileIdx].Hash)
+	return err
 }
 
 func (aero *Aero) Download(d Device, fileIdx int) int {
-	return aero.SocketServer.Download(d, fileIdx)
+	return aero.DownloadContext(context.Background(), d, fileIdx)
+}
+
+func (aero *Aero) DownloadContext(ctx context.Context, d Device, fileIdx int) int {
+	return aero.SocketServer.DownloadContext(ctx, d, fileIdx)
+}
+
+func (aero *Aero) DownloadDirectory(d Device, dir string, root string) []int {
+	return aero.DownloadDirectoryContext(context.Background(), d, dir, root)
+}
+
+func (aero *Aero) DownloadDirectoryContext(ctx context.Context, d Device, dir string, root string) []int {
+	return aero.SocketServer.DownloadDirectoryContext(ctx, d, dir, root)
+}
+
+func (aero *Aero) SwarmDownload(d Device, fileIdx int) int {
+	return aero.SwarmDownloadContext(context.Background(), d, fileIdx)
+}
+
+func (aero *Aero) SwarmDownloadContext(ctx context.Context, d Device, fileIdx int) int {
+	return aero.SocketServer.SwarmDownloadContext(ctx, d, fileIdx)
+}
+
+func (aero *Aero) ResumeDownload(downloadId int) error {
+	return aero.SocketServer.Resume(downloadId)
 }
 
-func (aero *Aero) initDevice(d *api.Device, master Device) ([]Device, error) {
-	conn, c, ctx, cancel, err := aero.createClient(master)
+func (aero *Aero) initDevice(ctx context.Context, d *api.Device, master Device) ([]Device, error) {
+	c, ctx, cancel, err := aero.createClient(ctx, master)
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
 	defer cancel()
 	out := make([]Device, 0)
 	data, err := c.Init(ctx, d)
 	if err != nil {
+		aero.logger.error("init", "cannot join master", logPeer(master), logErr(err))
 		return nil, err
 	}
 	for _, d := range data.Devices {
 		out = append(out, *GenerateDeviceFromAPIDevice(d))
 	}
-	aero.Devices = out
+	aero.registry.replace(out)
+	aero.registry.setTerm(data.Term)
+	aero.registry.setLeft(false)
+	aero.logger.info("init", fmt.Sprintf("joined master with %d devices", len(out)), logPeer(master))
 	return out, nil
 }
 
-func (aero *Aero) refreshDevice(d *api.Device) (Device, error) {
+func (aero *Aero) refreshDevice(ctx context.Context, d *api.Device) (Device, error) {
 	out := Device{}
-	conn, c, ctx, cancel, err := aero.createClient(aero.Devices[0])
+	master := aero.registry.masterDevice()
+	c, ctx, cancel, err := aero.createClient(ctx, master)
 	if err != nil {
 		return out, err
 	}
-	defer conn.Close()
 	defer cancel()
 
 	dev, err := c.Refresh(ctx, d)
 	if err != nil {
+		aero.logger.warn("refresh", "cannot refresh device on master", logPeer(master), logErr(err))
 		return out, err
 	}
 
@@ -171,32 +363,38 @@ func (aero *Aero) refreshDevice(d *api.Device) (Device, error) {
 	return out, nil
 }
 
-func (aero *Aero) getList() ([]Device, error) {
-	conn, c, ctx, cancel, err := aero.createClient(aero.Devices[0])
+func (aero *Aero) getList(ctx context.Context) ([]Device, error) {
+	data, err := aero.listDevices(ctx, aero.registry.masterDevice())
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
-	defer cancel()
-	out := make([]Device, 0)
-	data, err := c.List(ctx, &api.Void{})
-	if err != nil {
-		return nil, err
+	if !data.Master {
+		return nil, fmt.Errorf("device is no longer master")
 	}
+	out := make([]Device, 0)
 	for _, d := range data.Devices {
 		out = append(out, *GenerateDeviceFromAPIDevice(d))
 	}
-	aero.Devices = out
+	aero.registry.replace(out)
+	aero.registry.setTerm(data.Term)
 	return out, nil
 }
 
-func (aero *Aero) getStatus(d Device) (Device, error) {
+func (aero *Aero) listDevices(ctx context.Context, d Device) (*api.Devices, error) {
+	c, ctx, cancel, err := aero.createClient(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	return c.List(ctx, &api.Void{})
+}
+
+func (aero *Aero) getStatus(ctx context.Context, d Device) (Device, error) {
 	out := Device{}
-	conn, c, ctx, cancel, err := aero.createClient(d)
+	c, ctx, cancel, err := aero.createClient(ctx, d)
 	if err != nil {
 		return out, err
 	}
-	defer conn.Close()
 	defer cancel()
 
 	dev, err := c.Status(ctx, &api.Void{})
@@ -208,59 +406,133 @@ func (aero *Aero) getStatus(d Device) (Device, error) {
 	return out, nil
 }
 
-func (aero *Aero) fetchFile(d Device, fileIdx int) error {
-	conn, c, ctx, cancel, err := aero.createClient(d)
+func (aero *Aero) fetchTicket(ctx context.Context, d Device, hash string) (string, bool, error) {
+	c, ctx, cancel, err := aero.createClient(ctx, d)
 	if err != nil {
-		return err
+		return "", false, err
 	}
-	defer conn.Close()
 	defer cancel()
 
-	if fileIdx < 0 || fileIdx >= len(d.Files) {
-		return fmt.Errorf("file doesn't exists in the device")
+	resp, err := c.Fetch(ctx, &api.File{Hash: hash})
+	if err != nil {
+		return "", false, err
+	}
+
+	if !resp.Success {
+		return "", false, errors.New(resp.Error)
 	}
 
-	resp, err := c.Fetch(ctx, &api.File{Hash: d.Files[fileIdx].Hash})
+	return resp.Ticket, resp.Compression, nil
+}
+
+func (aero *Aero) getChunks(ctx context.Context, d Device, hash string) ([]string, error) {
+	c, ctx, cancel, err := aero.createClient(ctx, d)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer cancel()
 
-	if !resp.Success {
-		return fmt.Errorf(resp.Error)
+	manifest, err := c.Chunks(ctx, &api.File{Hash: hash})
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest.Hash != hash {
+		return nil, fmt.Errorf("manifest is for a different file")
+	}
+	if manifest.ChunkSize != chunkSize {
+		return nil, fmt.Errorf("unsupported chunk size %d", manifest.ChunkSize)
 	}
+	if MerkleRoot(manifest.Chunks) != manifest.Root {
+		return nil, fmt.Errorf("manifest root mismatch")
+	}
+	return manifest.Chunks, nil
+}
 
+func (aero *Aero) manifest(hash string) *api.Manifest {
+	for _, f := range aero.registry.getSelf().Files {
+		if f.Hash == hash {
+			return &api.Manifest{Hash: f.Hash, ChunkSize: chunkSize, Chunks: f.Chunks, Root: f.Root}
+		}
+	}
 	return nil
 }
 
-func (aero *Aero) createClient(d Device) (*grpc.ClientConn, api.ServiceClient, context.Context, context.CancelFunc, error) {
-	var (
-		conn *grpc.ClientConn
-		err  error
-	)
+func (aero *Aero) createClient(ctx context.Context, d Device) (api.ServiceClient, context.Context, context.CancelFunc, error) {
+	c, err := aero.dial(d)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ctx, cancel := aero.callContext(ctx)
+	return c, ctx, cancel, nil
+}
 
-	conn, err = grpc.Dial(d.Ip+":"+d.Port, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func (aero *Aero) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	aero.configMu.RLock()
+	timeout := aero.timeout
+	aero.configMu.RUnlock()
+	if timeout <= 0 {
+		return context.WithCancel(aero.authContext(ctx))
+	}
+	return context.WithTimeout(aero.authContext(ctx), timeout)
+}
 
+func (aero *Aero) dial(d Device) (api.ServiceClient, error) {
+	conn, err := aero.pool.get(d, func(addr string) (*grpc.ClientConn, error) {
+		creds := insecure.NewCredentials()
+		if aero.clientTLS != nil {
+			creds = credentials.NewTLS(aero.clientTLS)
+		}
+		return grpc.Dial(addr, grpc.WithTransportCredentials(creds), clientKeepalive(), grpc.WithDefaultServiceConfig(serviceConfig))
+	})
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, err
+	}
+	return api.NewServiceClient(conn), nil
+}
+
+func (aero *Aero) authContext(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "jwt", aero.generateToken())
+}
+
+func (aero *Aero) authInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if isHealthCheck(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	if err := aero.authorize(ctx); err != nil {
+		aero.logger.warn("grpc", "rejected "+info.FullMethod, logAddr(api.PeerIp(ctx)), logErr(err))
+		return nil, err
 	}
-	c := api.NewServiceClient(conn)
-	token := aero.generateToken()
-	ctx, cancel := context.WithTimeout(metadata.NewOutgoingContext(context.Background(), metadata.New(map[string]string{"jwt": token})), time.Second*10)
-	return conn, c, ctx, cancel, nil
+	return handler(ctx, req)
 }
 
-func (aero *Aero) authInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+func (aero *Aero) streamAuthInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isHealthCheck(info.FullMethod) {
+		return handler(srv, stream)
+	}
+	if err := aero.authorize(stream.Context()); err != nil {
+		aero.logger.warn("grpc", "rejected "+info.FullMethod, logAddr(api.PeerIp(stream.Context())), logErr(err))
+		return err
+	}
+	return handler(srv, stream)
+}
+
+func (aero *Aero) authorize(ctx context.Context) error {
 	meta, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return nil, status.Error(codes.Unauthenticated, "INTERNAL_SERVER_ERROR")
+		return status.Error(codes.Unauthenticated, "INTERNAL_SERVER_ERROR")
 	}
 	if len(meta["jwt"]) != 1 {
-		return nil, status.Error(codes.Unauthenticated, "token empty")
+		return status.Error(codes.Unauthenticated, "token empty")
 	}
 	if !auth.ValidToken(meta["jwt"][0], aero.key) {
-		return nil, status.Error(codes.PermissionDenied, "invalid auth token")
+		return status.Error(codes.PermissionDenied, "invalid auth token")
 	}
-	return handler(ctx, req)
+	return nil
+}
+
+func (aero *Aero) generateTicket(hash string, requester string) (string, error) {
+	return auth.GenerateTicket(aero.key, hash, requester)
 }
 
 func (aero *Aero) generateToken() string {
@@ -0,0 +1,208 @@
+package aero
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func freePort(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port
+}
+
+func startNode(t *testing.T, name string, master bool) *Aero {
+	t.Helper()
+	node := New(Device{Name: name, Ip: "127.0.0.1", Port: freePort(t), SocketPort: freePort(t)}, master)
+	node.SetKey("test-key")
+	if err := node.LoadIdentity(filepath.Join(t.TempDir(), "identity.pem")); err != nil {
+		t.Fatal(err)
+	}
+	go node.StartGrpcServer()
+	go node.StartSocketServer()
+	self := node.Self()
+	for _, port := range []string{self.Port, self.SocketPort} {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", port))
+			if err == nil {
+				conn.Close()
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s did not start listening on %s", name, port)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		node.Shutdown(ctx)
+	})
+	return &node
+}
+
+func writeRandomFile(t *testing.T, path string, size int) []byte {
+	t.Helper()
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func waitFor(t *testing.T, what string, status func() (DownloadStatus, error)) DownloadStatus {
+	t.Helper()
+	deadline := time.Now().Add(20 * time.Second)
+	for {
+		st, err := status()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.State == Done || st.State == Failed || st.State == Cancelled {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s did not finish: %+v", what, st)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestJoinDownloadSendLoopback(t *testing.T) {
+	dir := t.TempDir()
+	shared := writeRandomFile(t, filepath.Join(dir, "shared.bin"), 1<<20)
+	pushed := writeRandomFile(t, filepath.Join(dir, "pushed.bin"), 512<<10)
+	masterDir := filepath.Join(dir, "master")
+	nodeDir := filepath.Join(dir, "node")
+	os.MkdirAll(masterDir, 0755)
+	os.MkdirAll(nodeDir, 0755)
+
+	master := startNode(t, "master", true)
+	master.SetDownloadOptions(DownloadOptions{Dir: masterDir})
+	master.SetOfferHandler(func(from Device, file File) (bool, string) {
+		return true, ""
+	})
+	if err := master.AddFile(NewFile(filepath.Join(dir, "shared.bin"))); err != nil {
+		t.Fatal(err)
+	}
+
+	node := startNode(t, "node", false)
+	if _, err := node.SendInit(node.Self(), master.Self()); err != nil {
+		t.Fatal(err)
+	}
+
+	// settings change while transfers are running
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			node.SetCompression(i%2 == 0)
+			node.SetTimeout(defaultTimeout)
+			node.SetDownloadOptions(DownloadOptions{Dir: nodeDir})
+			node.SetProgressHandler(func(ProgressEvent) {}, 10*time.Millisecond)
+			node.SetJournal(nil)
+			node.SetOfferHandler(nil)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	devices, err := node.GetList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var source Device
+	for _, d := range devices {
+		if d.Name == "master" {
+			source = d
+		}
+	}
+	if len(source.Files) != 1 {
+		t.Fatalf("master is not sharing its file: %+v", source)
+	}
+
+	downloadId := node.Download(source, 0)
+	st := waitFor(t, "download", func() (DownloadStatus, error) { return node.DownloadStatus(downloadId) })
+	if st.State != Done {
+		t.Fatalf("download %s: %v", st.State, st.Error)
+	}
+	got, err := os.ReadFile(st.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, shared) {
+		t.Fatal("downloaded file differs from the shared file")
+	}
+
+	uploadId, err := node.Send(source, NewFile(filepath.Join(dir, "pushed.bin")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st = waitFor(t, "upload", func() (DownloadStatus, error) { return node.UploadStatus(uploadId) })
+	if st.State != Done {
+		t.Fatalf("upload %s: %v", st.State, st.Error)
+	}
+	got, err = os.ReadFile(filepath.Join(masterDir, "pushed.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, pushed) {
+		t.Fatal("received file differs from the pushed file")
+	}
+}
+
+func TestStopDoesNotWaitForRunningCalls(t *testing.T) {
+	master := startNode(t, "master", true)
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	master.SetOfferHandler(func(from Device, file File) (bool, string) {
+		close(entered)
+		<-release
+		return false, ""
+	})
+
+	node := startNode(t, "node", false)
+	if _, err := node.SendInit(node.Self(), master.Self()); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "offered.bin")
+	writeRandomFile(t, path, 1024)
+	go node.Send(master.Self(), NewFile(path))
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("offer did not reach the handler")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		master.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop waited for a running call")
+	}
+}
@@ -0,0 +1,193 @@
+package aero
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+type BandwidthLimits struct {
+	Upload       int64
+	Download     int64
+	PeerUpload   int64
+	PeerDownload int64
+	MaxUploads   int
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) setRate(rate int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rate = rate
+	b.tokens = 0
+	b.last = time.Now()
+}
+
+// the bucket may go into debt so writes larger than one second of rate still pass
+func (b *tokenBucket) take(n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate <= 0 {
+		return 0
+	}
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * float64(b.rate)
+	if b.tokens > float64(b.rate) {
+		b.tokens = float64(b.rate)
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / float64(b.rate) * float64(time.Second))
+}
+
+type bandwidth struct {
+	mu           sync.Mutex
+	limits       BandwidthLimits
+	upload       tokenBucket
+	download     tokenBucket
+	peerUpload   map[string]*tokenBucket
+	peerDownload map[string]*tokenBucket
+	active       int
+	queue        []chan struct{}
+}
+
+func (aero *Aero) SetBandwidthLimits(limits BandwidthLimits) {
+	aero.bandwidth.set(limits)
+}
+
+func (aero *Aero) BandwidthLimits() BandwidthLimits {
+	return aero.bandwidth.get()
+}
+
+func (b *bandwidth) set(limits BandwidthLimits) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limits = limits
+	b.upload.setRate(limits.Upload)
+	b.download.setRate(limits.Download)
+	for _, bucket := range b.peerUpload {
+		bucket.setRate(limits.PeerUpload)
+	}
+	for _, bucket := range b.peerDownload {
+		bucket.setRate(limits.PeerDownload)
+	}
+	for len(b.queue) > 0 && (limits.MaxUploads <= 0 || b.active < limits.MaxUploads) {
+		b.active++
+		close(b.queue[0])
+		b.queue = b.queue[1:]
+	}
+}
+
+func (b *bandwidth) get() BandwidthLimits {
+	if b == nil {
+		return BandwidthLimits{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.limits
+}
+
+func (b *bandwidth) peer(buckets *map[string]*tokenBucket, ip string, rate int64) *tokenBucket {
+	if *buckets == nil {
+		*buckets = make(map[string]*tokenBucket)
+	}
+	bucket, ok := (*buckets)[ip]
+	if !ok {
+		bucket = &tokenBucket{}
+		bucket.setRate(rate)
+		(*buckets)[ip] = bucket
+	}
+	return bucket
+}
+
+func (b *bandwidth) acquireUpload() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	if b.limits.MaxUploads <= 0 || b.active < b.limits.MaxUploads {
+		b.active++
+		b.mu.Unlock()
+		return
+	}
+	wait := make(chan struct{})
+	b.queue = append(b.queue, wait)
+	b.mu.Unlock()
+	<-wait
+}
+
+func (b *bandwidth) releaseUpload() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.queue) > 0 && (b.limits.MaxUploads <= 0 || b.active <= b.limits.MaxUploads) {
+		close(b.queue[0])
+		b.queue = b.queue[1:]
+		return
+	}
+	b.active--
+}
+
+func (b *bandwidth) writer(w io.Writer, ip string) io.Writer {
+	if b == nil {
+		return w
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return &throttledWriter{w: w, buckets: []*tokenBucket{&b.upload, b.peer(&b.peerUpload, ip, b.limits.PeerUpload)}}
+}
+
+func (b *bandwidth) reader(r io.Reader, ip string) io.Reader {
+	if b == nil {
+		return r
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return &throttledReader{r: r, buckets: []*tokenBucket{&b.download, b.peer(&b.peerDownload, ip, b.limits.PeerDownload)}}
+}
+
+func throttle(buckets []*tokenBucket, n int) {
+	var delay time.Duration
+	for _, bucket := range buckets {
+		if d := bucket.take(n); d > delay {
+			delay = d
+		}
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+type throttledWriter struct {
+	w       io.Writer
+	buckets []*tokenBucket
+}
+
+func (t *throttledWriter) Write(data []byte) (int, error) {
+	throttle(t.buckets, len(data))
+	return t.w.Write(data)
+}
+
+type throttledReader struct {
+	r       io.Reader
+	buckets []*tokenBucket
+}
+
+func (t *throttledReader) Read(data []byte) (int, error) {
+	n, err := t.r.Read(data)
+	if n > 0 {
+		throttle(t.buckets, n)
+	}
+	return n, err
+}
@@ -0,0 +1,102 @@
+package aero
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const compressionGzip = "gzip"
+
+var compressedTypes = []string{
+	"application/gzip",
+	"application/x-gzip",
+	"application/zip",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/x-xz",
+	"application/x-bzip2",
+	"application/zstd",
+	"application/vnd.rar",
+	"application/java-archive",
+	"application/epub+zip",
+	"application/pdf",
+	"image/jpeg",
+	"image/png",
+	"image/gif",
+	"image/webp",
+	"image/avif",
+	"image/heic",
+	"audio/",
+	"video/",
+	"font/woff",
+	"font/woff2",
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func (aero *Aero) SetCompression(enabled bool) {
+	aero.SocketServer.configMu.Lock()
+	defer aero.SocketServer.configMu.Unlock()
+	aero.SocketServer.compression = enabled
+}
+
+func (s *SocketServer) compressionEnabled() bool {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.compression
+}
+
+// gzip is only used when both ends have compression enabled
+func (s *SocketServer) compressionFor(f File, remote bool) string {
+	if !remote || !s.compressionEnabled() || !compressible(f.Type) {
+		return ""
+	}
+	return compressionGzip
+}
+
+func (s *SocketServer) acceptsCompression(compression string) bool {
+	return len(compression) == 0 || s.compressionEnabled()
+}
+
+func compressible(mimeType string) bool {
+	mimeType = strings.TrimSpace(strings.Split(mimeType, ";")[0])
+	for _, t := range compressedTypes {
+		if mimeType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mimeType, t)) {
+			return false
+		}
+	}
+	return true
+}
+
+func compressWriter(w io.Writer, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case "":
+		return nopWriteCloser{w}, nil
+	case compressionGzip:
+		return gzip.NewWriter(w), nil
+	}
+	return nil, fmt.Errorf("unsupported compression %s", compression)
+}
+
+func decompressReader(r io.Reader, compression string) (io.Reader, error) {
+	switch compression {
+	case "":
+		return r, nil
+	case compressionGzip:
+		reader, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		reader.Multistream(false)
+		return reader, nil
+	}
+	return nil, fmt.Errorf("unsupported compression %s", compression)
+}
@@ -5,30 +5,28 @@ import (
 )
 
 type Device struct {
+	Hash       string `json:"hash,omitempty"`
 	Name       string `json:"name,omitempty"`
 	Ip         string `json:"ip,omitempty"`
 	Port       string `json:"port,omitempty"`
 	SocketPort string `json:"socketPort,omitempty"`
 	Files      []File `json:"files,omitempty"`
+	Active     bool   `json:"active,omitempty"`
 }
 
 func GenerateAPIDeviceFromDevice(d *Device) *api.Device {
 	files := make([]*api.File, 0)
-	for _, f := range d.Files {
-		files = append(files, &api.File{
-			Name: f.Name,
-			Hash: f.Hash,
-			Ext:  f.Ext,
-			Type: f.Type,
-			Size: f.Size,
-		})
+	for i := range d.Files {
+		files = append(files, GenerateAPIFileFromFile(&d.Files[i]))
 	}
 	return &api.Device{
+		Hash:       d.Hash,
 		Name:       d.Name,
 		Ip:         d.Ip,
 		Port:       d.Port,
 		SocketPort: d.SocketPort,
 		Files:      files,
+		Active:     d.Active,
 	}
 }
 
@@ -38,10 +36,19 @@ func GenerateDeviceFromAPIDevice(d *api.Device) *Device {
 		files = append(files, *GenerateFileFromAPIFile(f))
 	}
 	return &Device{
+		Hash:       d.Hash,
 		Name:       d.Name,
 		Ip:         d.Ip,
 		Port:       d.Port,
 		SocketPort: d.SocketPort,
 		Files:      files,
+		Active:     d.Active,
+	}
+}
+
+func sameDevice(a Device, b Device) bool {
+	if len(a.Hash) > 0 && len(b.Hash) > 0 {
+		return a.Hash == b.Hash
 	}
+	return a.Ip == b.Ip && a.Port == b.Port
 }
@@ -0,0 +1,165 @@
+package aero
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	DiscoveryAddr      = "239.255.77.77:9999"
+	discoveryService   = "aero"
+	announceInterval   = time.Second
+	maxAnnouncementSz  = 1024
+	announcementLabel  = "aero discovery announcement"
+	maxAnnouncementAge = 10 * time.Second
+)
+
+type announcement struct {
+	Service    string `json:"service"`
+	Name       string `json:"name"`
+	Ip         string `json:"ip"`
+	Port       string `json:"port"`
+	SocketPort string `json:"socketPort"`
+	Time       int64  `json:"time"`
+	Signature  string `json:"signature"`
+}
+
+func (aero *Aero) SetDiscoveryAddr(addr string) {
+	aero.discoveryAddr = addr
+}
+
+func (aero *Aero) Advertise(ctx context.Context) error {
+	if !aero.IsMaster() {
+		return fmt.Errorf("node is not master")
+	}
+	if len(aero.key) == 0 {
+		return fmt.Errorf("auth key is not set")
+	}
+	addr, err := net.ResolveUDPAddr("udp4", aero.getDiscoveryAddr())
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialUDP("udp4", nil, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ticker := time.NewTicker(announceInterval)
+	defer ticker.Stop()
+	for {
+		self := aero.registry.getSelf()
+		a := announcement{
+			Service:    discoveryService,
+			Name:       self.Name,
+			Ip:         self.Ip,
+			Port:       self.Port,
+			SocketPort: self.SocketPort,
+			Time:       time.Now().Unix(),
+		}
+		a.Signature = aero.sign(a)
+		data, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		if _, err := conn.Write(data); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (aero *Aero) DiscoverMasters(ctx context.Context) ([]Device, error) {
+	if len(aero.key) == 0 {
+		return nil, fmt.Errorf("auth key is not set")
+	}
+	addr, err := net.ResolveUDPAddr("udp4", aero.getDiscoveryAddr())
+	if err != nil {
+		return nil, err
+	}
+
+	var conn *net.UDPConn
+	if addr.IP.IsMulticast() {
+		conn, err = net.ListenMulticastUDP("udp4", nil, addr)
+	} else {
+		conn, err = net.ListenUDP("udp4", &net.UDPAddr{Port: addr.Port})
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	go func() {
+		<-ctx.Done()
+		conn.SetReadDeadline(time.Now())
+	}()
+
+	out := make([]Device, 0)
+	seen := make(map[string]bool)
+	buffer := make([]byte, maxAnnouncementSz)
+	for {
+		n, from, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			if ctx.Err() != nil {
+				return out, nil
+			}
+			return out, err
+		}
+
+		a := announcement{}
+		if err := json.Unmarshal(buffer[:n], &a); err != nil {
+			continue
+		}
+		if a.Service != discoveryService || !aero.verify(a) {
+			continue
+		}
+		if len(a.Ip) == 0 {
+			a.Ip = from.IP.String()
+		}
+		if seen[a.Ip+":"+a.Port] {
+			continue
+		}
+		seen[a.Ip+":"+a.Port] = true
+		out = append(out, Device{Name: a.Name, Ip: a.Ip, Port: a.Port, SocketPort: a.SocketPort})
+	}
+}
+
+func (aero *Aero) getDiscoveryAddr() string {
+	if len(aero.discoveryAddr) == 0 {
+		return DiscoveryAddr
+	}
+	return aero.discoveryAddr
+}
+
+// the hmac covers every field and the send time, so a captured announcement cannot be altered or replayed for long
+func (aero *Aero) sign(a announcement) string {
+	mac := hmac.New(sha256.New, []byte(aero.key))
+	for _, field := range []string{announcementLabel, a.Service, a.Name, a.Ip, a.Port, a.SocketPort, strconv.FormatInt(a.Time, 10)} {
+		mac.Write([]byte(strconv.Itoa(len(field)) + ":" + field))
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (aero *Aero) verify(a announcement) bool {
+	age := time.Since(time.Unix(a.Time, 0))
+	if age > maxAnnouncementAge || age < -maxAnnouncementAge {
+		return false
+	}
+	signature, err := hex.DecodeString(a.Signature)
+	if err != nil {
+		return false
+	}
+	expected, _ := hex.DecodeString(aero.sign(a))
+	return hmac.Equal(signature, expected)
+}
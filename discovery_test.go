@@ -0,0 +1,138 @@
+package aero
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func freeUDPAddr(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().String()
+}
+
+func TestDiscoverMastersLoopback(t *testing.T) {
+	addr := freeUDPAddr(t)
+	master := New(Device{Name: "master", Ip: "127.0.0.1", Port: "9000", SocketPort: "9001"}, true)
+	master.SetKey("secret")
+	master.SetDiscoveryAddr(addr)
+	stranger := New(Device{Name: "stranger", Ip: "127.0.0.1", Port: "9100", SocketPort: "9101"}, true)
+	stranger.SetKey("other")
+	stranger.SetDiscoveryAddr(addr)
+	node := New(Device{Name: "node"}, false)
+	node.SetKey("secret")
+	node.SetDiscoveryAddr(addr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		master.Advertise(ctx)
+	}()
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		stranger.Advertise(ctx)
+	}()
+
+	found, err := node.DiscoverMasters(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 {
+		t.Fatalf("want 1 master, have %d: %v", len(found), found)
+	}
+	if found[0].Name != "master" || found[0].Port != "9000" || found[0].SocketPort != "9001" {
+		t.Fatalf("unexpected master %+v", found[0])
+	}
+}
+
+func TestDiscoverMastersMulticast(t *testing.T) {
+	_, port, _ := net.SplitHostPort(freeUDPAddr(t))
+	addr := "239.255.77.78:" + port
+	group, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe, err := net.DialUDP("udp4", nil, group)
+	if err != nil {
+		t.Skipf("no multicast route: %v", err)
+	}
+	_, err = probe.Write([]byte("probe"))
+	probe.Close()
+	if err != nil {
+		t.Skipf("no multicast route: %v", err)
+	}
+
+	master := New(Device{Name: "master", Ip: "127.0.0.1", Port: "9000", SocketPort: "9001"}, true)
+	master.SetKey("secret")
+	master.SetDiscoveryAddr(addr)
+	node := New(Device{Name: "node"}, false)
+	node.SetKey("secret")
+	node.SetDiscoveryAddr(addr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		master.Advertise(ctx)
+	}()
+
+	found, err := node.DiscoverMasters(ctx)
+	if err != nil {
+		t.Skipf("cannot join multicast group: %v", err)
+	}
+	if len(found) != 1 || found[0].Name != "master" {
+		t.Fatalf("want the master over multicast, have %v", found)
+	}
+}
+
+func TestVerifyAnnouncement(t *testing.T) {
+	master := New(Device{}, true)
+	master.SetKey("secret")
+	stranger := New(Device{}, true)
+	stranger.SetKey("other")
+
+	signed := func(signer *Aero, change func(a *announcement)) announcement {
+		a := announcement{Service: discoveryService, Name: "master", Ip: "10.0.0.1", Port: "9000", SocketPort: "9001", Time: time.Now().Unix()}
+		a.Signature = signer.sign(a)
+		if change != nil {
+			change(&a)
+		}
+		return a
+	}
+	stale := func(a *announcement) {
+		a.Time = time.Now().Add(-time.Minute).Unix()
+		a.Signature = master.sign(*a)
+	}
+	future := func(a *announcement) {
+		a.Time = time.Now().Add(time.Minute).Unix()
+		a.Signature = master.sign(*a)
+	}
+
+	tests := []struct {
+		name  string
+		a     announcement
+		valid bool
+	}{
+		{"signed with the shared key", signed(&master, nil), true},
+		{"signed with another key", signed(&stranger, nil), false},
+		{"port changed after signing", signed(&master, func(a *announcement) { a.Port = "9100" }), false},
+		{"ip changed after signing", signed(&master, func(a *announcement) { a.Ip = "10.0.0.2" }), false},
+		{"time changed after signing", signed(&master, func(a *announcement) { a.Time-- }), false},
+		{"stale", signed(&master, stale), false},
+		{"from the future", signed(&master, future), false},
+		{"signature is not hex", signed(&master, func(a *announcement) { a.Signature = "zz" }), false},
+		{"no signature", signed(&master, func(a *announcement) { a.Signature = "" }), false},
+	}
+	for _, test := range tests {
+		if valid := master.verify(test.a); valid != test.valid {
+			t.Errorf("%s: want %v, have %v", test.name, test.valid, valid)
+		}
+	}
+}
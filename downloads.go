@@ -0,0 +1,379 @@
+package aero
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+type DownloadState int
+
+const (
+	Queued DownloadState = iota
+	Running
+	Paused
+	Verifying
+	Done
+	Failed
+	Cancelled
+)
+
+func (state DownloadState) String() string {
+	switch state {
+	case Queued:
+		return "queued"
+	case Running:
+		return "running"
+	case Paused:
+		return "paused"
+	case Verifying:
+		return "verifying"
+	case Done:
+		return "done"
+	case Failed:
+		return "failed"
+	case Cancelled:
+		return "cancelled"
+	}
+	return fmt.Sprintf("DownloadState(%d)", int(state))
+}
+
+type DownloadStatus struct {
+	Id          int
+	Name        string
+	Hash        string
+	Path        string
+	Device      Device
+	Sources     int
+	State       DownloadState
+	FileSize    int64
+	Received    int64
+	Progress    int
+	HashMatched bool
+	Error       error
+}
+
+func (aero *Aero) DownloadStatus(downloadId int) (DownloadStatus, error) {
+	return aero.SocketServer.DownloadStatus(downloadId)
+}
+
+func (aero *Aero) ListDownloads() []DownloadStatus {
+	return aero.SocketServer.ListDownloads()
+}
+
+func (aero *Aero) PauseDownload(downloadId int) error {
+	return aero.SocketServer.Pause(downloadId)
+}
+
+func (aero *Aero) CancelDownload(downloadId int) error {
+	return aero.SocketServer.Cancel(downloadId)
+}
+
+func (aero *Aero) SetMaxDownloads(max int) {
+	aero.SocketServer.SetMaxDownloads(max)
+}
+
+func (s *SocketServer) DownloadStatus(downloadId int) (DownloadStatus, error) {
+	progressWriter, err := s.getDownload(downloadId)
+	if err != nil {
+		return DownloadStatus{}, err
+	}
+	return progressWriter.status(), nil
+}
+
+func (s *SocketServer) ListDownloads() []DownloadStatus {
+	s.downloadsMu.Lock()
+	out := make([]DownloadStatus, 0, len(s.downloads))
+	for _, progressWriter := range s.downloads {
+		out = append(out, progressWriter.status())
+	}
+	s.downloadsMu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Id < out[j].Id })
+	return out
+}
+
+func (s *SocketServer) SetMaxDownloads(max int) {
+	s.downloadsMu.Lock()
+	s.maxDownloads = max
+	s.downloadsMu.Unlock()
+	s.schedule()
+}
+
+func (s *SocketServer) Pause(downloadId int) error {
+	progressWriter, err := s.getDownload(downloadId)
+	if err != nil {
+		return err
+	}
+	if err := progressWriter.halt(Paused); err != nil {
+		return err
+	}
+	s.recordDownload(progressWriter)
+	s.schedule()
+	return nil
+}
+
+func (s *SocketServer) Cancel(downloadId int) error {
+	progressWriter, err := s.getDownload(downloadId)
+	if err != nil {
+		return err
+	}
+	if err := progressWriter.halt(Cancelled); err != nil {
+		return err
+	}
+	s.recordDownload(progressWriter)
+	s.schedule()
+	return nil
+}
+
+func (s *SocketServer) getDownload(downloadId int) (*ProgressWriter, error) {
+	s.downloadsMu.Lock()
+	defer s.downloadsMu.Unlock()
+	progressWriter, ok := s.downloads[downloadId]
+	if !ok {
+		return nil, fmt.Errorf("download %d not found", downloadId)
+	}
+	return progressWriter, nil
+}
+
+func (s *SocketServer) tracked(key string) bool {
+	s.downloadsMu.Lock()
+	defer s.downloadsMu.Unlock()
+	for _, progressWriter := range s.downloads {
+		progressWriter.mu.Lock()
+		found := progressWriter.key == key
+		progressWriter.mu.Unlock()
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SocketServer) reserved(path string) bool {
+	s.downloadsMu.Lock()
+	defer s.downloadsMu.Unlock()
+	for _, progressWriter := range s.downloads {
+		progressWriter.mu.Lock()
+		active := progressWriter.path == path && progressWriter.state != Done && progressWriter.state != Failed && progressWriter.state != Cancelled
+		progressWriter.mu.Unlock()
+		if active {
+			return true
+		}
+	}
+	return s.offered(path)
+}
+
+func (s *SocketServer) addDownload(progressWriter *ProgressWriter, start bool) int {
+	s.downloadsMu.Lock()
+	if s.downloads == nil {
+		s.downloads = make(map[int]*ProgressWriter)
+	}
+	s.lastDownload++
+	progressWriter.id = s.lastDownload
+	s.downloads[progressWriter.id] = progressWriter
+	s.downloadsMu.Unlock()
+	s.recordDownload(progressWriter)
+	if start {
+		s.schedule()
+	}
+	return progressWriter.id
+}
+
+func (s *SocketServer) schedule() {
+	if s.stopping() {
+		return
+	}
+	s.downloadsMu.Lock()
+	defer s.downloadsMu.Unlock()
+
+	ids := make([]int, 0, len(s.downloads))
+	running := 0
+	for id, progressWriter := range s.downloads {
+		switch progressWriter.getState() {
+		case Running, Verifying:
+			running++
+		case Queued:
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		if s.maxDownloads > 0 && running >= s.maxDownloads {
+			return
+		}
+		progressWriter := s.downloads[id]
+		if !progressWriter.begin() {
+			continue
+		}
+		running++
+		go s.run(progressWriter)
+	}
+}
+
+func (s *SocketServer) run(progressWriter *ProgressWriter) {
+	progressWriter.mu.Lock()
+	file := progressWriter.device.Files[progressWriter.fileIdx]
+	sources := progressWriter.sources
+	offset := progressWriter.offset
+	progressWriter.mu.Unlock()
+
+	s.recordDownload(progressWriter)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-progressWriter.context().Done():
+			s.Cancel(progressWriter.id)
+		case <-done:
+		}
+	}()
+	finish := s.trackProgress(ProgressEvent{Kind: DownloadTransfer, Id: progressWriter.id, Name: file.Name, Hash: file.Hash, Device: progressWriter.device}, progressWriter)
+	if len(sources) > 1 {
+		s.swarmDownload(sources, file, progressWriter, offset > 0)
+	} else {
+		s.download(progressWriter.device, progressWriter.fileIdx, progressWriter, offset)
+	}
+
+	progressWriter.mu.Lock()
+	switch {
+	case progressWriter.state == Cancelled:
+		os.Remove(progressWriter.target)
+	case progressWriter.state == Paused:
+	case progressWriter.HashMatched:
+		progressWriter.state = Done
+	default:
+		progressWriter.state = Failed
+		if progressWriter.Error == nil {
+			progressWriter.Error = fmt.Errorf("download stopped")
+		}
+	}
+	state := progressWriter.state
+	progressWriter.mu.Unlock()
+	close(done)
+	finish(state)
+	s.recordDownload(progressWriter)
+	s.schedule()
+}
+
+func (pw *ProgressWriter) begin() bool {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.state != Queued {
+		return false
+	}
+	pw.state = Running
+	pw.stop = make(chan struct{})
+	return true
+}
+
+func (pw *ProgressWriter) halt(state DownloadState) error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	switch pw.state {
+	case Running, Verifying:
+		close(pw.stop)
+	case Queued, Paused:
+		if pw.state == state {
+			return nil
+		}
+		if state == Cancelled && pw.Received > 0 {
+			os.Remove(pw.target)
+		}
+	default:
+		return fmt.Errorf("download %d is already %s", pw.id, pw.state)
+	}
+	pw.state = state
+	return nil
+}
+
+func (pw *ProgressWriter) context() context.Context {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.ctx == nil {
+		return context.Background()
+	}
+	return pw.ctx
+}
+
+func (pw *ProgressWriter) getState() DownloadState {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	return pw.state
+}
+
+func (pw *ProgressWriter) stopped() bool {
+	state := pw.getState()
+	return state == Paused || state == Cancelled
+}
+
+func (pw *ProgressWriter) setState(state DownloadState) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.state == Running || pw.state == Verifying {
+		pw.state = state
+	}
+}
+
+func (pw *ProgressWriter) fail(err error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.state == Paused || pw.state == Cancelled || pw.state == Failed {
+		return
+	}
+	pw.Error = err
+	pw.HashMatched = false
+}
+
+func (pw *ProgressWriter) complete() {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.Received = pw.FileSize
+	pw.Progress = 100
+	pw.HashMatched = true
+}
+
+func (pw *ProgressWriter) watch(c io.Closer) func() {
+	pw.mu.Lock()
+	stop := pw.stop
+	pw.mu.Unlock()
+	if stop == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-stop:
+			c.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (pw *ProgressWriter) status() DownloadStatus {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	status := DownloadStatus{
+		Id:          pw.id,
+		Path:        pw.path,
+		Device:      pw.device,
+		Sources:     len(pw.sources),
+		State:       pw.state,
+		FileSize:    pw.FileSize,
+		Received:    pw.Received,
+		Progress:    pw.Progress,
+		HashMatched: pw.HashMatched,
+		Error:       pw.Error,
+	}
+	if pw.fileIdx < len(pw.device.Files) {
+		status.Name = pw.device.Files[pw.fileIdx].Name
+		status.Hash = pw.device.Files[pw.fileIdx].Hash
+	}
+	if status.Sources == 0 {
+		status.Sources = 1
+	}
+	return status
+}
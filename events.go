@@ -0,0 +1,90 @@
+package aero
+
+import (
+	"context"
+
+	"github.com/dhamith93/aero/internal/api"
+)
+
+const eventBufferSize = 64
+
+type EventType int
+
+const (
+	DeviceJoined EventType = iota
+	DeviceLeft
+	FileAdded
+	FileRemoved
+)
+
+type Event struct {
+	Type   EventType `json:"type"`
+	Device Device    `json:"device"`
+	File   File      `json:"file"`
+}
+
+func (aero *Aero) Subscribe(ctx context.Context) (<-chan Event, error) {
+	out := make(chan Event, eventBufferSize)
+	if aero.IsMaster() {
+		events, unsubscribe := aero.Server.Subscribe()
+		go func() {
+			defer close(out)
+			defer unsubscribe()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case e, ok := <-events:
+					if !ok {
+						return
+					}
+					select {
+					case out <- GenerateEventFromAPIEvent(e):
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+		return out, nil
+	}
+
+	c, err := aero.dial(aero.registry.masterDevice())
+	if err != nil {
+		return nil, err
+	}
+	stream, err := c.Watch(aero.authContext(ctx), &api.Void{})
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer close(out)
+		for {
+			e, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			event := GenerateEventFromAPIEvent(e)
+			if event.Type == DeviceLeft {
+				aero.registry.remove(event.Device)
+			}
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func GenerateEventFromAPIEvent(e *api.Event) Event {
+	event := Event{Type: EventType(e.Type)}
+	if e.Device != nil {
+		event.Device = *GenerateDeviceFromAPIDevice(e.Device)
+	}
+	if e.File != nil {
+		event.File = *GenerateFileFromAPIFile(e.File)
+	}
+	return event
+}
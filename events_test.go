@@ -0,0 +1,25 @@
+package aero
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSubscribeEndsWhenMasterStops(t *testing.T) {
+	master := startNode(t, "master", true)
+	events, err := master.Subscribe(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	master.Stop()
+
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("want the event channel to close after Stop")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event channel is still open after Stop")
+	}
+}
@@ -0,0 +1,170 @@
+package aero
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dhamith93/aero/internal/api"
+)
+
+const maxMasterMisses = 3
+
+func (aero *Aero) WatchMaster(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	misses := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if aero.registry.hasLeft() {
+			continue
+		}
+		if aero.IsMaster() {
+			aero.checkMasters(ctx)
+			continue
+		}
+		master := aero.registry.masterDevice()
+		if len(master.Ip) == 0 {
+			continue
+		}
+		// refreshing the list doubles as the master check and keeps every node electing from the same members
+		if _, err := aero.getList(ctx); err == nil {
+			misses = 0
+			continue
+		}
+		misses++
+		aero.logger.warn("failover", fmt.Sprintf("master missed %d status checks", misses), logPeer(master))
+		if misses < maxMasterMisses {
+			continue
+		}
+		if err := aero.failover(ctx); err == nil {
+			misses = 0
+		} else {
+			aero.logger.error("failover", "election failed", logErr(err))
+		}
+	}
+}
+
+// the reachable device with the lowest id takes over as master
+func (aero *Aero) failover(ctx context.Context) error {
+	self := aero.registry.getSelf()
+	devices := aero.registry.snapshot()
+	candidates := make([]Device, 0)
+	for _, d := range devices[1:] {
+		if !sameDevice(d, self) {
+			candidates = append(candidates, d)
+		}
+	}
+	candidates = append(candidates, self)
+	sort.Slice(candidates, func(i, j int) bool {
+		return electionKey(candidates[i]) < electionKey(candidates[j])
+	})
+
+	term := aero.registry.getTerm()
+	for _, d := range candidates {
+		if sameDevice(d, self) {
+			// a device this node could not see first may already have taken over
+			for _, other := range candidates {
+				if sameDevice(other, self) {
+					continue
+				}
+				data, err := aero.listDevices(ctx, other)
+				if err != nil {
+					continue
+				}
+				if data.Master {
+					return aero.joinMaster(ctx, other)
+				}
+				if data.Term > term {
+					term = data.Term
+				}
+			}
+			return aero.promote(candidates, term+1)
+		}
+		data, err := aero.listDevices(ctx, d)
+		if err != nil {
+			continue
+		}
+		if !data.Master {
+			return fmt.Errorf("waiting for %s to take over", d.Name)
+		}
+		return aero.joinMaster(ctx, d)
+	}
+	return fmt.Errorf("no master candidate available")
+}
+
+// a master steps down for another master with a newer term, or the same term and a lower id
+func (aero *Aero) checkMasters(ctx context.Context) {
+	self := aero.registry.getSelf()
+	term := aero.registry.getTerm()
+	for _, d := range aero.registry.snapshot() {
+		if sameDevice(d, self) {
+			continue
+		}
+		data, err := aero.listDevices(ctx, d)
+		if err != nil || !data.Master {
+			continue
+		}
+		if data.Term > term || (data.Term == term && electionKey(d) < electionKey(self)) {
+			if err := aero.demote(ctx, d); err != nil {
+				aero.logger.error("failover", "cannot join newer master", logPeer(d), logErr(err))
+			}
+			return
+		}
+	}
+}
+
+func (aero *Aero) joinMaster(ctx context.Context, master Device) error {
+	aero.logger.info("failover", "joining new master", logPeer(master))
+	self := aero.registry.getSelf()
+	_, err := aero.initDevice(ctx, GenerateAPIDeviceFromDevice(&self), master)
+	return err
+}
+
+func (aero *Aero) demote(ctx context.Context, master Device) error {
+	aero.logger.warn("failover", "another master took over, stepping down", logPeer(master))
+	aero.Server.SetMaster(false)
+	aero.registry.setMaster(false)
+	return aero.joinMaster(ctx, master)
+}
+
+func (aero *Aero) promote(registry []Device, term int64) error {
+	apiSelf := aero.Server.GetSelf()
+	if apiSelf == nil {
+		return fmt.Errorf("grpc server is not started")
+	}
+
+	self := aero.registry.getSelf()
+	devices := []Device{self}
+	apiDevices := []*api.Device{apiSelf}
+	for i := range registry {
+		if !sameDevice(registry[i], self) {
+			device := GenerateAPIDeviceFromDevice(&registry[i])
+			device.LastSeen = time.Now().Unix()
+			devices = append(devices, registry[i])
+			apiDevices = append(apiDevices, device)
+		}
+	}
+
+	aero.Server.SetDevices(apiDevices)
+	aero.Server.SetTerm(term)
+	aero.Server.SetMaster(true)
+	aero.registry.setTerm(term)
+	aero.registry.setMaster(true)
+	aero.registry.replace(devices)
+	aero.logger.info("failover", fmt.Sprintf("promoted to master for term %d with %d devices", term, len(devices)))
+	return nil
+}
+
+func electionKey(d Device) string {
+	if len(d.Hash) > 0 {
+		return d.Hash
+	}
+	return d.Ip + ":" + d.Port
+}
@@ -0,0 +1,74 @@
+package aero
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dhamith93/aero/internal/api"
+)
+
+func eventually(t *testing.T, what string, check func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !check() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func knows(a *Aero, d Device) bool {
+	for _, device := range a.Devices() {
+		if sameDevice(device, d) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFailoverElectsOneMasterAndDemotesStaleMaster(t *testing.T) {
+	master := startNode(t, "master", true)
+	first := startNode(t, "first", false)
+	second := startNode(t, "second", false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// first joins before second, so only the list refresh tells it about second
+	if _, err := first.SendInit(first.Self(), master.Self()); err != nil {
+		t.Fatal(err)
+	}
+	go first.WatchMaster(ctx, 100*time.Millisecond)
+	if _, err := second.SendInit(second.Self(), master.Self()); err != nil {
+		t.Fatal(err)
+	}
+	go second.WatchMaster(ctx, 100*time.Millisecond)
+	eventually(t, "first to learn about second", func() bool { return knows(first, second.Self()) })
+
+	master.Stop()
+	winner, loser := first, second
+	if electionKey(second.Self()) < electionKey(first.Self()) {
+		winner, loser = second, first
+	}
+	eventually(t, "the lowest id to take over", func() bool { return winner.IsMaster() })
+	eventually(t, "the other node to join the new master", func() bool {
+		return sameDevice(loser.registry.masterDevice(), winner.Self()) && knows(winner, loser.Self())
+	})
+	if loser.IsMaster() {
+		t.Fatal("two masters after failover")
+	}
+	if winner.registry.getTerm() != 1 || loser.registry.getTerm() != 1 {
+		t.Fatalf("want term 1 on both nodes, have %d and %d", winner.registry.getTerm(), loser.registry.getTerm())
+	}
+
+	// a master left over from an older term steps down once it sees the new one
+	stale := startNode(t, "stale", true)
+	staleSelf, winnerSelf := stale.Self(), winner.Self()
+	stale.Server.SetDevices([]*api.Device{GenerateAPIDeviceFromDevice(&staleSelf), GenerateAPIDeviceFromDevice(&winnerSelf)})
+	go stale.WatchMaster(ctx, 100*time.Millisecond)
+	eventually(t, "the stale master to step down", func() bool { return !stale.IsMaster() && knows(winner, staleSelf) })
+	if !winner.IsMaster() {
+		t.Fatal("the newer master stepped down")
+	}
+}
@@ -3,20 +3,28 @@ package aero
 import (
 	"crypto/sha256"
 	b64 "encoding/base64"
+	"hash"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 
 	"github.com/dhamith93/aero/internal/api"
 	"github.com/gabriel-vasile/mimetype"
 )
 
+const chunkSize = 1024 * 1024
+
 type File struct {
-	Name string `json:"name,omitempty"`
-	Hash string `json:"hash,omitempty"`
-	Type string `json:"type,omitempty"`
-	Ext  string `json:"ext,omitempty"`
-	Path string `json:"path,omitempty"`
-	Size int64  `json:"size,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	Hash    string   `json:"hash,omitempty"`
+	Type    string   `json:"type,omitempty"`
+	Ext     string   `json:"ext,omitempty"`
+	Path    string   `json:"path,omitempty"`
+	Size    int64    `json:"size,omitempty"`
+	RelPath string   `json:"relPath,omitempty"`
+	Chunks  []string `json:"chunks,omitempty"`
+	Root    string   `json:"root,omitempty"`
 }
 
 func NewFile(path string) File {
@@ -26,6 +34,30 @@ func NewFile(path string) File {
 	return file
 }
 
+func NewDirectory(path string) ([]File, error) {
+	root := filepath.Clean(path)
+	files := make([]File, 0)
+	err := filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(filepath.Dir(root), p)
+		if err != nil {
+			return err
+		}
+		file := File{Path: p, RelPath: filepath.ToSlash(rel)}
+		if err := file.setMeta(); err != nil {
+			return err
+		}
+		files = append(files, file)
+		return nil
+	})
+	return files, err
+}
+
 func (file *File) setMeta() error {
 	f, err := os.Open(file.Path)
 	if err != nil {
@@ -47,8 +79,16 @@ func (file *File) setMeta() error {
 	file.Type = mtype.String()
 	file.Size = fileInfo.Size()
 	file.Name = fileInfo.Name()
-	file.Hash, err = GetHash(f)
-	return err
+
+	h := sha256.New()
+	chunks := newChunkHasher()
+	if _, err := io.Copy(io.MultiWriter(h, chunks), f); err != nil {
+		return err
+	}
+	file.Hash = b64.StdEncoding.EncodeToString(h.Sum(nil))
+	file.Chunks = chunks.Sum()
+	file.Root = MerkleRoot(file.Chunks)
+	return nil
 }
 
 func GetHash(f *os.File) (string, error) {
@@ -59,12 +99,137 @@ func GetHash(f *os.File) (string, error) {
 	return b64.StdEncoding.EncodeToString(h.Sum(nil)), nil
 }
 
+func MerkleRoot(chunks []string) string {
+	if len(chunks) == 0 {
+		return ""
+	}
+	level := make([][]byte, 0)
+	for _, c := range chunks {
+		leaf, err := b64.StdEncoding.DecodeString(c)
+		if err != nil {
+			return ""
+		}
+		level = append(level, leaf)
+	}
+	for len(level) > 1 {
+		next := make([][]byte, 0)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			sum := sha256.Sum256(append(append([]byte{}, level[i]...), level[i+1]...))
+			next = append(next, sum[:])
+		}
+		level = next
+	}
+	return b64.StdEncoding.EncodeToString(level[0])
+}
+
+type chunkHasher struct {
+	hash   hash.Hash
+	filled int64
+	chunks []string
+}
+
+func newChunkHasher() *chunkHasher {
+	return &chunkHasher{hash: sha256.New(), chunks: make([]string, 0)}
+}
+
+func (c *chunkHasher) Write(data []byte) (int, error) {
+	written := 0
+	for len(data) > 0 {
+		n := int(chunkSize - c.filled)
+		if n > len(data) {
+			n = len(data)
+		}
+		c.hash.Write(data[:n])
+		c.filled += int64(n)
+		data = data[n:]
+		written += n
+		if c.filled == chunkSize {
+			c.next()
+		}
+	}
+	return written, nil
+}
+
+func (c *chunkHasher) next() {
+	if c.filled == 0 {
+		return
+	}
+	c.chunks = append(c.chunks, b64.StdEncoding.EncodeToString(c.hash.Sum(nil)))
+	c.hash.Reset()
+	c.filled = 0
+}
+
+func (c *chunkHasher) Sum() []string {
+	c.next()
+	return c.chunks
+}
+
+type chunkVerifier struct {
+	hasher *chunkHasher
+	chunks []string
+	index  int
+	size   int64
+	bad    []byteRange
+}
+
+func newChunkVerifier(chunks []string, size int64, offset int64) *chunkVerifier {
+	return &chunkVerifier{hasher: newChunkHasher(), chunks: chunks, index: int(offset / chunkSize), size: size}
+}
+
+func (v *chunkVerifier) Write(data []byte) (int, error) {
+	n, err := v.hasher.Write(data)
+	v.check()
+	return n, err
+}
+
+func (v *chunkVerifier) Finish() []byteRange {
+	if v == nil {
+		return nil
+	}
+	v.hasher.next()
+	v.check()
+	return v.bad
+}
+
+func (v *chunkVerifier) check() {
+	for _, sum := range v.hasher.chunks {
+		if v.index >= len(v.chunks) || v.chunks[v.index] != sum {
+			offset := int64(v.index) * chunkSize
+			length := int64(chunkSize)
+			if offset+length > v.size {
+				length = v.size - offset
+			}
+			if length > 0 {
+				v.bad = append(v.bad, byteRange{offset: offset, length: length})
+			}
+		}
+		v.index++
+	}
+	v.hasher.chunks = v.hasher.chunks[:0]
+}
+
+func GenerateAPIFileFromFile(f *File) *api.File {
+	return &api.File{
+		Name:    f.Name,
+		Hash:    f.Hash,
+		Ext:     f.Ext,
+		Type:    f.Type,
+		Size:    f.Size,
+		RelPath: f.RelPath,
+	}
+}
+
 func GenerateFileFromAPIFile(f *api.File) *File {
 	return &File{
-		Name: f.Name,
-		Hash: f.Hash,
-		Ext:  f.Ext,
-		Type: f.Type,
-		Size: f.Size,
+		Name:    f.Name,
+		Hash:    f.Hash,
+		Ext:     f.Ext,
+		Type:    f.Type,
+		Size:    f.Size,
+		RelPath: f.RelPath,
 	}
 }
@@ -0,0 +1,90 @@
+package aero
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	b64 "encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestChunkVerifierReportsOnlyCorruptedRange(t *testing.T) {
+	size := int64(3*chunkSize + chunkSize/2)
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := NewFile(path)
+	if len(file.Chunks) != 4 {
+		t.Fatalf("want 4 chunks, have %d", len(file.Chunks))
+	}
+
+	corrupt := func(at int64) []byte {
+		out := append([]byte{}, data...)
+		out[at] ^= 0xff
+		return out
+	}
+	tests := []struct {
+		name   string
+		data   []byte
+		offset int64
+		want   []byteRange
+	}{
+		{"intact", data, 0, nil},
+		{"second chunk corrupted", corrupt(chunkSize + 10), 0, []byteRange{{offset: chunkSize, length: chunkSize}}},
+		{"partial last chunk corrupted", corrupt(size - 1), 0, []byteRange{{offset: 3 * chunkSize, length: size - 3*chunkSize}}},
+		{"corrupted after resuming", corrupt(2*chunkSize + 1), 2 * chunkSize, []byteRange{{offset: 2 * chunkSize, length: chunkSize}}},
+	}
+	for _, test := range tests {
+		verifier := newChunkVerifier(file.Chunks, size, test.offset)
+		// uneven writes cross chunk boundaries
+		for rest := test.data[test.offset:]; len(rest) > 0; {
+			n := 300 * 1024
+			if n > len(rest) {
+				n = len(rest)
+			}
+			verifier.Write(rest[:n])
+			rest = rest[n:]
+		}
+		if have := verifier.Finish(); !reflect.DeepEqual(have, test.want) {
+			t.Errorf("%s: want %v, have %v", test.name, test.want, have)
+		}
+	}
+}
+
+func TestMerkleRoot(t *testing.T) {
+	leaf := func(s string) []byte {
+		sum := sha256.Sum256([]byte(s))
+		return sum[:]
+	}
+	node := func(left []byte, right []byte) []byte {
+		sum := sha256.Sum256(append(append([]byte{}, left...), right...))
+		return sum[:]
+	}
+	encode := b64.StdEncoding.EncodeToString
+	a, b, c := leaf("a"), leaf("b"), leaf("c")
+
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{"no chunks", nil, ""},
+		{"one chunk", []string{encode(a)}, encode(a)},
+		{"two chunks", []string{encode(a), encode(b)}, encode(node(a, b))},
+		{"odd chunk is carried up", []string{encode(a), encode(b), encode(c)}, encode(node(node(a, b), c))},
+		{"order matters", []string{encode(b), encode(a)}, encode(node(b, a))},
+		{"invalid chunk", []string{encode(a), "not base64!"}, ""},
+	}
+	for _, test := range tests {
+		if have := MerkleRoot(test.chunks); have != test.want {
+			t.Errorf("%s: want %s, have %s", test.name, test.want, have)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package aero
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultHeartbeatInterval = 5 * time.Second
+	defaultInactiveAfter     = 15 * time.Second
+	defaultRemoveAfter       = time.Minute
+)
+
+type heartbeatConfig struct {
+	interval      time.Duration
+	inactiveAfter time.Duration
+	removeAfter   time.Duration
+}
+
+func (aero *Aero) SetHeartbeat(interval time.Duration, inactiveAfter time.Duration, removeAfter time.Duration) {
+	aero.heartbeat = heartbeatConfig{interval: interval, inactiveAfter: inactiveAfter, removeAfter: removeAfter}
+}
+
+func (aero *Aero) StartHeartbeat(ctx context.Context) error {
+	conf := aero.heartbeat
+	if conf.interval == 0 {
+		conf = heartbeatConfig{interval: defaultHeartbeatInterval, inactiveAfter: defaultInactiveAfter, removeAfter: defaultRemoveAfter}
+	}
+
+	ticker := time.NewTicker(conf.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if aero.IsMaster() {
+			aero.Server.Evict(conf.inactiveAfter, conf.removeAfter)
+			continue
+		}
+		if aero.registry.hasLeft() {
+			continue
+		}
+		master := aero.registry.masterDevice()
+		err := aero.sendHeartbeat(ctx)
+		if status.Code(err) == codes.NotFound {
+			aero.logger.info("heartbeat", "master does not know this device, joining again", logPeer(master))
+			self := aero.registry.getSelf()
+			aero.initDevice(ctx, GenerateAPIDeviceFromDevice(&self), master)
+		} else if err != nil {
+			aero.logger.warn("heartbeat", "heartbeat failed", logPeer(master), logErr(err))
+		}
+	}
+}
+
+func (aero *Aero) sendHeartbeat(ctx context.Context) error {
+	c, ctx, cancel, err := aero.createClient(ctx, aero.registry.masterDevice())
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	self := aero.registry.getSelf()
+	_, err = c.Heartbeat(ctx, GenerateAPIDeviceFromDevice(&self))
+	return err
+}
@@ -0,0 +1,109 @@
+package aero
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	b64 "encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+func (aero *Aero) LoadIdentity(path string) error {
+	key, err := loadOrCreateKey(path)
+	if err != nil {
+		return err
+	}
+	aero.registry.updateSelf(func(self *Device) error {
+		self.Hash = deviceHash(key.Public().(ed25519.PublicKey))
+		return nil
+	})
+	return nil
+}
+
+// without LoadIdentity the key is kept per grpc port in the user config directory
+func (aero *Aero) ensureIdentity() error {
+	_, err := aero.registry.updateSelf(func(self *Device) error {
+		if len(self.Hash) > 0 {
+			return nil
+		}
+		path, err := defaultIdentityPath(self.Port)
+		if err != nil {
+			return err
+		}
+		key, err := loadOrCreateKey(path)
+		if err != nil {
+			return err
+		}
+		self.Hash = deviceHash(key.Public().(ed25519.PublicKey))
+		return nil
+	})
+	return err
+}
+
+// a device passed without an id keeps the one already loaded
+func (aero *Aero) adoptSelf(d Device) Device {
+	self, _ := aero.registry.updateSelf(func(self *Device) error {
+		hash := self.Hash
+		*self = copyDevice(d)
+		if len(self.Hash) == 0 {
+			self.Hash = hash
+		}
+		return nil
+	})
+	return self
+}
+
+func defaultIdentityPath(port string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot find a place for the identity key, use LoadIdentity: %w", err)
+	}
+	dir = filepath.Join(dir, "aero")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "identity-"+port+".pem"), nil
+}
+
+func loadOrCreateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return key, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no identity key found in %s", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("identity key in %s is not ed25519", path)
+	}
+	return key, nil
+}
+
+func deviceHash(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return b64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,52 @@
+package aero
+
+import (
+	"testing"
+)
+
+func TestSendInitKeepsLoadedIdentity(t *testing.T) {
+	master := startNode(t, "master", true)
+	node := startNode(t, "node", false)
+	loaded := node.Self()
+
+	devices, err := node.SendInit(Device{Name: loaded.Name, Ip: loaded.Ip, Port: loaded.Port, SocketPort: loaded.SocketPort}, master.Self())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Self().Hash != loaded.Hash {
+		t.Fatalf("loaded id %s was replaced by %s", loaded.Hash, node.Self().Hash)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("want master and node on the master, have %d devices", len(devices))
+	}
+
+	if _, err := node.SendRefresh(Device{Name: loaded.Name, Ip: loaded.Ip, Port: loaded.Port, SocketPort: loaded.SocketPort}); err != nil {
+		t.Fatal(err)
+	}
+	if node.Self().Hash != loaded.Hash {
+		t.Fatal("refresh replaced the loaded id")
+	}
+}
+
+func TestDefaultIdentitySurvivesRestart(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	identity := func(port string) string {
+		node := New(Device{Name: "node", Port: port}, false)
+		if err := node.ensureIdentity(); err != nil {
+			t.Fatal(err)
+		}
+		return node.Self().Hash
+	}
+	first := identity("9000")
+	if len(first) == 0 {
+		t.Fatal("no id was generated")
+	}
+	if again := identity("9000"); again != first {
+		t.Fatalf("restart changed the id from %s to %s", first, again)
+	}
+	if other := identity("9100"); other == first {
+		t.Fatal("instances on different ports share an id")
+	}
+}
@@ -3,62 +3,268 @@ package api
 import (
 	context "context"
 	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	LevelDebug = -4
+	LevelInfo  = 0
+	LevelWarn  = 4
+	LevelError = 8
 )
 
 type Server struct {
-	Devices  []*Device
-	Self     *Device
-	Listener *chan bool
-	IsMaster bool
+	Manifest    func(hash string) *Manifest
+	Ticket      func(hash string, requester string) (string, error)
+	Accept      func(offer *FileOffer, requester string) (string, error)
+	Compression func() bool
+	Log         func(level int, message string, peer *Device, err error)
+	mu          sync.RWMutex
+	devices     []*Device
+	self        *Device
+	master      bool
+	term        int64
+	subMu       sync.Mutex
+	subscribers map[chan *Event]bool
+	unwatched   bool
+	previous    []*Device
+	changes     chan struct{}
 }
 
 func (s *Server) Init(ctx context.Context, in *Device) (*Devices, error) {
-	if !s.IsMaster {
+	s.mu.Lock()
+	if !s.master {
+		s.mu.Unlock()
 		return nil, fmt.Errorf("node is not master")
 	}
-	s.Devices = append(s.Devices, in)
-	devices := make([]*Device, 0)
-	for i := range s.Devices {
-		devices = append(devices, s.Devices[i])
+	device := proto.Clone(in).(*Device)
+	device.Active = true
+	device.LastSeen = time.Now().Unix()
+	rejoined := false
+	if i := s.indexOf(device); i >= 0 {
+		s.devices[i] = device
+		rejoined = true
+	} else {
+		s.devices = append(s.devices, device)
 	}
-	*s.Listener <- true
-	return &Devices{Devices: devices}, nil
+	devices := cloneDevices(s.devices)
+	term := s.term
+	s.mu.Unlock()
+
+	if rejoined {
+		s.log(LevelInfo, "device joined again", in, nil)
+	} else {
+		s.log(LevelInfo, "device joined", in, nil)
+	}
+	s.notify()
+	return &Devices{Devices: devices, Term: term, Master: true}, nil
 }
 
 func (s *Server) Refresh(ctx context.Context, in *Device) (*Device, error) {
-	if !s.IsMaster {
+	s.mu.Lock()
+	if !s.master {
+		s.mu.Unlock()
 		return nil, fmt.Errorf("node is not master")
 	}
-	out := Device{}
-	for i := range s.Devices {
-		if s.Devices[i].Hash == in.Hash {
-			s.Devices[i].Files = in.Files
-			s.Devices[i].Active = in.Active
-			*s.Listener <- true
-			return s.Devices[i], nil
-		}
+	i := s.indexOf(in)
+	if i < 0 {
+		s.mu.Unlock()
+		s.log(LevelWarn, "refresh from unknown device", in, nil)
+		return &Device{}, fmt.Errorf("did not find a matching device")
 	}
-	return &out, fmt.Errorf("did not find a matching device")
+	s.devices[i].Files = proto.Clone(in).(*Device).Files
+	s.devices[i].Active = true
+	s.devices[i].LastSeen = time.Now().Unix()
+	out := proto.Clone(s.devices[i]).(*Device)
+	s.mu.Unlock()
+
+	s.notify()
+	s.log(LevelDebug, fmt.Sprintf("device refreshed with %d files", len(in.Files)), in, nil)
+	return out, nil
 }
 
-func (s *Server) List(ctx context.Context, in *Void) (*Devices, error) {
+func (s *Server) Heartbeat(ctx context.Context, in *Device) (*Void, error) {
+	s.mu.Lock()
+	if !s.master {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("node is not master")
+	}
+	i := s.indexOf(in)
+	if i < 0 {
+		s.mu.Unlock()
+		s.log(LevelWarn, "heartbeat from unknown device", in, nil)
+		return nil, status.Error(codes.NotFound, "did not find a matching device")
+	}
+	s.devices[i].LastSeen = time.Now().Unix()
+	reactivated := !s.devices[i].Active
+	s.devices[i].Active = true
+	s.mu.Unlock()
+
+	if reactivated {
+		s.log(LevelInfo, "device is active again", in, nil)
+		s.notify()
+	}
+	return &Void{}, nil
+}
+
+func (s *Server) Leave(ctx context.Context, in *Device) (*Void, error) {
+	s.mu.Lock()
+	if !s.master {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("node is not master")
+	}
+	i := s.indexOf(in)
+	if i < 0 {
+		s.mu.Unlock()
+		s.log(LevelWarn, "leave from unknown device", in, nil)
+		return nil, status.Error(codes.NotFound, "did not find a matching device")
+	}
+	if s.self != nil && SameDevice(s.devices[i], s.self) {
+		s.mu.Unlock()
+		return nil, status.Error(codes.InvalidArgument, "master cannot leave")
+	}
+	s.devices = append(s.devices[:i], s.devices[i+1:]...)
+	s.mu.Unlock()
+
+	s.log(LevelInfo, "device left", in, nil)
+	s.notify()
+	return &Void{}, nil
+}
+
+func (s *Server) Evict(inactiveAfter time.Duration, removeAfter time.Duration) {
+	s.mu.Lock()
+	if !s.master {
+		s.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	removed := make([]*Device, 0)
+	inactive := make([]*Device, 0)
 	devices := make([]*Device, 0)
-	for i := range s.Devices {
-		devices = append(devices, s.Devices[i])
+	for _, d := range s.devices {
+		if s.self != nil && SameDevice(d, s.self) {
+			devices = append(devices, d)
+			continue
+		}
+		lastSeen := time.Unix(d.LastSeen, 0)
+		if now.Sub(lastSeen) > removeAfter {
+			removed = append(removed, d)
+			continue
+		}
+		if d.Active && now.Sub(lastSeen) > inactiveAfter {
+			d.Active = false
+			inactive = append(inactive, proto.Clone(d).(*Device))
+		}
+		devices = append(devices, d)
 	}
-	return &Devices{Devices: devices}, nil
+	s.devices = devices
+	s.mu.Unlock()
+
+	for _, d := range removed {
+		s.log(LevelInfo, "device removed after missing heartbeats", d, nil)
+	}
+	for _, d := range inactive {
+		s.log(LevelInfo, "device marked inactive after missing heartbeats", d, nil)
+	}
+	if len(removed) > 0 || len(inactive) > 0 {
+		s.notify()
+	}
+}
+
+func (s *Server) List(ctx context.Context, in *Void) (*Devices, error) {
+	s.mu.RLock()
+	term, master := s.term, s.master
+	s.mu.RUnlock()
+	return &Devices{Devices: s.Snapshot(), Term: term, Master: master}, nil
 }
 
 func (s *Server) Status(ctx context.Context, in *Void) (*Device, error) {
-	return s.Self, nil
+	return s.GetSelf(), nil
 }
 
 func (s *Server) Fetch(ctx context.Context, in *File) (*FetchResponse, error) {
-	for _, f := range s.Self.Files {
+	self := s.GetSelf()
+	if self == nil {
+		return &FetchResponse{Success: false, Error: "file not found"}, nil
+	}
+	for _, f := range self.Files {
 		if f.Hash == in.Hash {
-			return &FetchResponse{Success: true, Error: ""}, nil
+			if s.Ticket == nil {
+				return &FetchResponse{Success: true, Error: ""}, nil
+			}
+			host, err := requester(ctx)
+			if err != nil {
+				return &FetchResponse{Success: false, Error: err.Error()}, nil
+			}
+			ticket, err := s.Ticket(in.Hash, host)
+			if err != nil {
+				s.log(LevelError, "cannot issue ticket for "+in.Hash, &Device{Ip: host}, err)
+				return &FetchResponse{Success: false, Error: err.Error()}, nil
+			}
+			s.log(LevelDebug, "issued ticket for "+in.Hash, &Device{Ip: host}, nil)
+			return &FetchResponse{Success: true, Error: "", Ticket: ticket, Compression: s.compression()}, nil
 		}
 	}
 
+	s.log(LevelWarn, "fetch for unknown file "+in.Hash, &Device{Ip: PeerIp(ctx)}, nil)
 	return &FetchResponse{Success: false, Error: "file not found"}, nil
 }
+
+func (s *Server) Chunks(ctx context.Context, in *File) (*Manifest, error) {
+	if s.Manifest == nil {
+		return nil, fmt.Errorf("chunk manifests not available")
+	}
+	manifest := s.Manifest(in.Hash)
+	if manifest == nil {
+		return nil, fmt.Errorf("file not found")
+	}
+	return manifest, nil
+}
+
+func (s *Server) Offer(ctx context.Context, in *FileOffer) (*OfferResponse, error) {
+	if s.Accept == nil {
+		return &OfferResponse{Accepted: false, Error: "device does not accept offers"}, nil
+	}
+	host, err := requester(ctx)
+	if err != nil {
+		return &OfferResponse{Accepted: false, Error: err.Error()}, nil
+	}
+	ticket, err := s.Accept(in, host)
+	if err != nil {
+		s.log(LevelInfo, "declined offer for "+in.File.GetName(), in.From, err)
+		return &OfferResponse{Accepted: false, Error: err.Error()}, nil
+	}
+	s.log(LevelInfo, "accepted offer for "+in.File.GetName(), in.From, nil)
+	return &OfferResponse{Accepted: true, Ticket: ticket, Compression: s.compression()}, nil
+}
+
+func (s *Server) log(level int, message string, peer *Device, err error) {
+	if s.Log != nil {
+		s.Log(level, message, peer, err)
+	}
+}
+
+func PeerIp(ctx context.Context) string {
+	host, _ := requester(ctx)
+	return host
+}
+
+func requester(ctx context.Context) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", fmt.Errorf("cannot identify requester")
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	return host, err
+}
+
+func (s *Server) compression() bool {
+	return s.Compression != nil && s.Compression()
+}
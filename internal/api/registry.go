@@ -0,0 +1,90 @@
+package api
+
+import (
+	"google.golang.org/protobuf/proto"
+)
+
+func (s *Server) SetMaster(master bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.master = master
+}
+
+func (s *Server) Master() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.master
+}
+
+func (s *Server) SetTerm(term int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.term = term
+}
+
+func (s *Server) Term() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.term
+}
+
+func (s *Server) SetSelf(d *Device) {
+	s.mu.Lock()
+	s.self = proto.Clone(d).(*Device)
+	s.mu.Unlock()
+}
+
+func (s *Server) GetSelf() *Device {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.self == nil {
+		return nil
+	}
+	return proto.Clone(s.self).(*Device)
+}
+
+func (s *Server) SetDevices(devices []*Device) {
+	s.mu.Lock()
+	s.devices = cloneDevices(devices)
+	s.mu.Unlock()
+	s.notify()
+}
+
+func (s *Server) Snapshot() []*Device {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return cloneDevices(s.devices)
+}
+
+func (s *Server) Changes() <-chan struct{} {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	if s.changes == nil {
+		s.changes = make(chan struct{}, 1)
+	}
+	return s.changes
+}
+
+func (s *Server) indexOf(in *Device) int {
+	for i := range s.devices {
+		if SameDevice(s.devices[i], in) {
+			return i
+		}
+	}
+	return -1
+}
+
+func SameDevice(a *Device, b *Device) bool {
+	if len(a.Hash) > 0 && len(b.Hash) > 0 {
+		return a.Hash == b.Hash
+	}
+	return a.Ip == b.Ip && a.Port == b.Port
+}
+
+func cloneDevices(devices []*Device) []*Device {
+	out := make([]*Device, 0, len(devices))
+	for _, d := range devices {
+		out = append(out, proto.Clone(d).(*Device))
+	}
+	return out
+}
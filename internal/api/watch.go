@@ -0,0 +1,131 @@
+package api
+
+import (
+	"fmt"
+)
+
+const eventBufferSize = 64
+
+func (s *Server) Watch(in *Void, stream Service_WatchServer) error {
+	if !s.Master() {
+		return fmt.Errorf("node is not master")
+	}
+	events, unsubscribe := s.Subscribe()
+	defer unsubscribe()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) Subscribe() (chan *Event, func()) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	if s.unwatched {
+		events := make(chan *Event)
+		close(events)
+		return events, func() {}
+	}
+	if s.subscribers == nil {
+		s.subscribers = make(map[chan *Event]bool)
+	}
+	if len(s.subscribers) == 0 {
+		s.previous = s.Snapshot()
+	}
+	events := make(chan *Event, eventBufferSize)
+	s.subscribers[events] = true
+	return events, func() {
+		s.subMu.Lock()
+		defer s.subMu.Unlock()
+		delete(s.subscribers, events)
+	}
+}
+
+// CloseWatchers ends every Watch stream so the grpc server can stop gracefully
+func (s *Server) CloseWatchers() {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	s.unwatched = true
+	for events := range s.subscribers {
+		close(events)
+		delete(s.subscribers, events)
+	}
+}
+
+func (s *Server) notify() {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	if len(s.subscribers) > 0 {
+		current := s.Snapshot()
+		for _, event := range diffDevices(s.previous, current) {
+			for events := range s.subscribers {
+				select {
+				case events <- event:
+				default:
+				}
+			}
+		}
+		s.previous = current
+	}
+	if s.changes == nil {
+		s.changes = make(chan struct{}, 1)
+	}
+	select {
+	case s.changes <- struct{}{}:
+	default:
+	}
+}
+
+func diffDevices(old []*Device, current []*Device) []*Event {
+	events := make([]*Event, 0)
+	for _, d := range current {
+		prev := findDevice(old, d)
+		if prev == nil {
+			events = append(events, &Event{Type: Event_DEVICE_JOINED, Device: d})
+			continue
+		}
+		for _, f := range d.Files {
+			if findFile(prev.Files, f) == nil {
+				events = append(events, &Event{Type: Event_FILE_ADDED, Device: d, File: f})
+			}
+		}
+		for _, f := range prev.Files {
+			if findFile(d.Files, f) == nil {
+				events = append(events, &Event{Type: Event_FILE_REMOVED, Device: d, File: f})
+			}
+		}
+	}
+	for _, d := range old {
+		if findDevice(current, d) == nil {
+			events = append(events, &Event{Type: Event_DEVICE_LEFT, Device: d})
+		}
+	}
+	return events
+}
+
+func findDevice(devices []*Device, d *Device) *Device {
+	for _, device := range devices {
+		if SameDevice(device, d) {
+			return device
+		}
+	}
+	return nil
+}
+
+func findFile(files []*File, f *File) *File {
+	for _, file := range files {
+		if file.Hash == f.Hash {
+			return file
+		}
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"time"
 
@@ -36,3 +38,38 @@ func GenerateJWT(key string) (string, error) {
 
 	return tokenString, nil
 }
+
+func GenerateTicket(key string, hash string, requester string) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	token := jwt.New(jwt.SigningMethodHS256)
+	claims := token.Claims.(jwt.MapClaims)
+
+	claims["type"] = "ticket"
+	claims["jti"] = hex.EncodeToString(id)
+	claims["hash"] = hash
+	claims["requester"] = requester
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = time.Now().Add(time.Minute).Unix()
+
+	return token.SignedString([]byte(key))
+}
+
+func ValidTicket(ticket string, key string, hash string, requester string) bool {
+	t, err := jwt.Parse(ticket, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("error with method")
+		}
+		return []byte(key), nil
+	})
+	if err != nil || !t.Valid {
+		return false
+	}
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok {
+		return false
+	}
+	return claims["type"] == "ticket" && claims["hash"] == hash && claims["requester"] == requester
+}
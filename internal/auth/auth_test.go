@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func signedTicket(t *testing.T, key string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestValidTicket(t *testing.T) {
+	const key, hash, requester = "secret", "file-hash", "192.168.1.3"
+	ticket, err := GenerateTicket(key, hash, requester)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := GenerateJWT(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"type": "ticket", "hash": hash, "requester": requester, "exp": time.Now().Add(time.Minute).Unix()}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{"type": "ticket", "hash": hash, "requester": requester, "exp": time.Now().Add(time.Minute).Unix()}
+		change(c)
+		return c
+	}
+
+	tests := []struct {
+		name      string
+		ticket    string
+		key       string
+		hash      string
+		requester string
+		valid     bool
+	}{
+		{"issued ticket", ticket, key, hash, requester, true},
+		{"wrong hash", ticket, key, "other-hash", requester, false},
+		{"wrong requester", ticket, key, hash, "192.168.1.4", false},
+		{"ipv6 requester for an ipv4 ticket", ticket, key, hash, "::1", false},
+		{"wrong key", ticket, "other", hash, requester, false},
+		{"expired", signedTicket(t, key, claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() })), key, hash, requester, false},
+		{"wrong type", signedTicket(t, key, claims(func(c jwt.MapClaims) { c["type"] = "session" })), key, hash, requester, false},
+		{"missing type", signedTicket(t, key, claims(func(c jwt.MapClaims) { delete(c, "type") })), key, hash, requester, false},
+		{"unsigned", unsigned, key, hash, requester, false},
+		{"session token", session, key, hash, requester, false},
+		{"not a token", "not-a-token", key, hash, requester, false},
+		{"empty", "", key, hash, requester, false},
+	}
+	for _, test := range tests {
+		if valid := ValidTicket(test.ticket, test.key, test.hash, test.requester); valid != test.valid {
+			t.Errorf("%s: want %v, have %v", test.name, test.valid, valid)
+		}
+	}
+}
+
+func TestTicketsAreUnique(t *testing.T) {
+	first, err := GenerateTicket("secret", "file-hash", "192.168.1.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := GenerateTicket("secret", "file-hash", "192.168.1.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatal("two tickets for the same file and requester are identical")
+	}
+}
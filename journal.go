@@ -0,0 +1,318 @@
+package aero
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type JournalEntry struct {
+	Key     string        `json:"key"`
+	Kind    TransferKind  `json:"kind"`
+	State   DownloadState `json:"state"`
+	File    File          `json:"file"`
+	Device  Device        `json:"device"`
+	Path    string        `json:"path,omitempty"`
+	Target  string        `json:"target,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	Started time.Time     `json:"started"`
+	Updated time.Time     `json:"updated"`
+}
+
+type Journal interface {
+	Record(entry JournalEntry) error
+	Entries() ([]JournalEntry, error)
+}
+
+type JournalQuery struct {
+	Kinds  []TransferKind
+	States []DownloadState
+	Hash   string
+	Device string
+	Since  time.Time
+	Until  time.Time
+}
+
+type FileJournal struct {
+	path string
+	file *os.File
+	mu   sync.Mutex
+}
+
+func NewFileJournal(path string) (*FileJournal, error) {
+	j := &FileJournal{path: path}
+	entries, err := j.read()
+	if err != nil {
+		return nil, err
+	}
+	if err := j.compact(entries); err != nil {
+		return nil, err
+	}
+	j.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *FileJournal) Record(entry JournalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.file.Write(append(data, '\n'))
+	return err
+}
+
+func (j *FileJournal) Entries() ([]JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.read()
+}
+
+func (j *FileJournal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+func (j *FileJournal) read() ([]JournalEntry, error) {
+	file, err := os.Open(j.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []JournalEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	latest := make(map[string]JournalEntry)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry := JournalEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		latest[entry.Key] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	entries := make([]JournalEntry, 0, len(latest))
+	for _, entry := range latest {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, k int) bool { return entries[i].Started.Before(entries[k].Started) })
+	return entries, nil
+}
+
+func (j *FileJournal) compact(entries []JournalEntry) error {
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), filepath.Base(j.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		writer.Write(append(data, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), j.path)
+}
+
+func (aero *Aero) SetJournal(journal Journal) {
+	aero.SocketServer.configMu.Lock()
+	defer aero.SocketServer.configMu.Unlock()
+	aero.SocketServer.journal = journal
+}
+
+func (aero *Aero) History(query JournalQuery) ([]JournalEntry, error) {
+	journal := aero.SocketServer.getJournal()
+	if journal == nil {
+		return nil, errors.New("journal is not set")
+	}
+	entries, err := journal.Entries()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]JournalEntry, 0)
+	for _, entry := range entries {
+		if query.matches(entry) {
+			out = append(out, entry)
+		}
+	}
+	return out, nil
+}
+
+func (aero *Aero) ResumeUnfinished() ([]int, error) {
+	return aero.SocketServer.ResumeUnfinished()
+}
+
+func (s *SocketServer) ResumeUnfinished() ([]int, error) {
+	journal := s.getJournal()
+	if journal == nil {
+		return nil, errors.New("journal is not set")
+	}
+	entries, err := journal.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0)
+	for _, entry := range entries {
+		if entry.Kind != DownloadTransfer || len(entry.Target) == 0 {
+			continue
+		}
+		if entry.State != Queued && entry.State != Running && entry.State != Paused && entry.State != Verifying {
+			continue
+		}
+		if s.tracked(entry.Key) {
+			continue
+		}
+
+		device := entry.Device
+		for _, d := range s.registry.snapshot() {
+			if sameDevice(d, device) {
+				device = d
+				break
+			}
+		}
+		device.Files = []File{entry.File}
+
+		var offset int64
+		info, err := os.Stat(entry.Target)
+		if err == nil && info.Size() < entry.File.Size {
+			offset = info.Size() - info.Size()%chunkSize
+		}
+
+		progressWriter := &ProgressWriter{FileSize: entry.File.Size, Received: offset, device: device, path: entry.Path, target: entry.Target, offset: offset, key: entry.Key, started: entry.Started}
+		if entry.File.Size > 0 {
+			progressWriter.Progress = int((offset * 100) / entry.File.Size)
+		}
+		ids = append(ids, s.addDownload(progressWriter, true))
+	}
+	return ids, nil
+}
+
+func (q JournalQuery) matches(entry JournalEntry) bool {
+	if len(q.Kinds) > 0 {
+		found := false
+		for _, kind := range q.Kinds {
+			found = found || kind == entry.Kind
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(q.States) > 0 {
+		found := false
+		for _, state := range q.States {
+			found = found || state == entry.State
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(q.Hash) > 0 && q.Hash != entry.File.Hash {
+		return false
+	}
+	if len(q.Device) > 0 && q.Device != entry.Device.Hash && q.Device != entry.Device.Ip && q.Device != entry.Device.Name {
+		return false
+	}
+	if !q.Since.IsZero() && entry.Updated.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && entry.Updated.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+func (s *SocketServer) getJournal() Journal {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.journal
+}
+
+func (s *SocketServer) record(entry JournalEntry) {
+	journal := s.getJournal()
+	if journal == nil {
+		return
+	}
+	if len(entry.Key) == 0 {
+		entry.Key = journalKey()
+	}
+	if entry.Started.IsZero() {
+		entry.Started = time.Now()
+	}
+	entry.Updated = time.Now()
+	entry.File.Chunks = nil
+	entry.Device.Files = nil
+	if err := journal.Record(entry); err != nil {
+		s.log(LevelError, "journal", "cannot record transfer", logHash(entry.File.Hash), logErr(err))
+	}
+}
+
+func (s *SocketServer) recordDownload(progressWriter *ProgressWriter) {
+	if s.getJournal() == nil {
+		return
+	}
+	progressWriter.mu.Lock()
+	if len(progressWriter.key) == 0 {
+		progressWriter.key = journalKey()
+		progressWriter.started = time.Now()
+	}
+	entry := JournalEntry{
+		Key:     progressWriter.key,
+		Kind:    DownloadTransfer,
+		State:   progressWriter.state,
+		Device:  progressWriter.device,
+		Path:    progressWriter.path,
+		Target:  progressWriter.target,
+		Started: progressWriter.started,
+	}
+	if progressWriter.fileIdx < len(progressWriter.device.Files) {
+		entry.File = progressWriter.device.Files[progressWriter.fileIdx]
+	}
+	if progressWriter.Error != nil {
+		entry.Error = progressWriter.Error.Error()
+	}
+	progressWriter.mu.Unlock()
+	s.record(entry)
+}
+
+func journalKey() string {
+	key := make([]byte, 8)
+	rand.Read(key)
+	return hex.EncodeToString(key)
+}
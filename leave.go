@@ -0,0 +1,90 @@
+package aero
+
+import (
+	"context"
+	"fmt"
+)
+
+func (aero *Aero) Leave() error {
+	return aero.LeaveContext(context.Background())
+}
+
+func (aero *Aero) LeaveContext(ctx context.Context) error {
+	if err := aero.leave(ctx); err != nil {
+		return err
+	}
+	aero.registry.replace([]Device{})
+	return nil
+}
+
+func (aero *Aero) leave(ctx context.Context) error {
+	if aero.IsMaster() {
+		return fmt.Errorf("master cannot leave")
+	}
+	if aero.registry.hasLeft() {
+		return fmt.Errorf("device already left")
+	}
+	master := aero.registry.masterDevice()
+	c, ctx, cancel, err := aero.createClient(ctx, master)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	self := aero.registry.getSelf()
+	if _, err := c.Leave(ctx, GenerateAPIDeviceFromDevice(&self)); err != nil {
+		aero.logger.warn("leave", "cannot leave master", logPeer(master), logErr(err))
+		return err
+	}
+	aero.registry.setLeft(true)
+	aero.logger.info("leave", "left master", logPeer(master))
+	return nil
+}
+
+func (aero *Aero) deviceDeparted(d Device) {
+	aero.logger.info("leave", "device left", logPeer(d))
+	aero.SocketServer.dropDevice(d)
+}
+
+func (s *SocketServer) dropDevice(d Device) {
+	s.downloadsMu.Lock()
+	involved := make([]*ProgressWriter, 0)
+	for _, progressWriter := range s.downloads {
+		progressWriter.mu.Lock()
+		if sameDevice(progressWriter.device, d) && len(progressWriter.sources) < 2 {
+			involved = append(involved, progressWriter)
+		}
+		progressWriter.mu.Unlock()
+	}
+	s.downloadsMu.Unlock()
+
+	for _, progressWriter := range involved {
+		if err := progressWriter.halt(Failed); err != nil {
+			continue
+		}
+		progressWriter.mu.Lock()
+		progressWriter.Error = fmt.Errorf("device %s left", d.Name)
+		progressWriter.mu.Unlock()
+		s.recordDownload(progressWriter)
+		s.log(LevelWarn, "download", "stopped download from departed device", logPeer(d))
+	}
+	if len(involved) > 0 {
+		s.schedule()
+	}
+
+	s.mu.Lock()
+	for ticket, o := range s.offers {
+		if sameDevice(o.from, d) {
+			delete(s.offers, ticket)
+		}
+	}
+	s.mu.Unlock()
+
+	s.serverMu.Lock()
+	defer s.serverMu.Unlock()
+	for connection, peer := range s.conns {
+		if (len(peer.Ip) > 0 || len(peer.Hash) > 0) && sameDevice(peer, d) {
+			connection.Close()
+		}
+	}
+}
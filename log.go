@@ -0,0 +1,184 @@
+package aero
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhamith93/aero/internal/api"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelWarn  Level = 4
+	LevelError Level = 8
+)
+
+func (l Level) String() string {
+	switch {
+	case l < LevelInfo:
+		return "DEBUG"
+	case l < LevelWarn:
+		return "INFO"
+	case l < LevelError:
+		return "WARN"
+	}
+	return "ERROR"
+}
+
+type LogEntry struct {
+	Time      time.Time
+	Level     Level
+	Component string
+	Message   string
+	Peer      string
+	PeerName  string
+	Hash      string
+	Err       error
+}
+
+func (e LogEntry) String() string {
+	parts := make([]string, 0)
+	if len(e.Component) > 0 {
+		parts = append(parts, e.Component+":")
+	}
+	parts = append(parts, e.Message)
+	if len(e.Peer) > 0 {
+		parts = append(parts, strings.TrimSpace("peer: "+e.PeerName+" "+e.Peer))
+	}
+	if len(e.Hash) > 0 {
+		parts = append(parts, "hash: "+e.Hash)
+	}
+	if e.Err != nil {
+		parts = append(parts, "error: "+e.Err.Error())
+	}
+	return strings.Join(parts, " ")
+}
+
+func (e LogEntry) messageType() string {
+	switch {
+	case e.Level >= LevelError:
+		return ERR
+	case e.Level >= LevelWarn:
+		return WRN
+	}
+	return MSG
+}
+
+type LogSink interface {
+	Log(entry LogEntry)
+}
+
+type logField func(entry *LogEntry)
+
+func logPeer(d Device) logField {
+	return func(entry *LogEntry) {
+		entry.Peer = d.Ip
+		entry.PeerName = d.Name
+	}
+}
+
+func logAddr(ip string) logField {
+	return func(entry *LogEntry) {
+		entry.Peer = ip
+	}
+}
+
+func logHash(hash string) logField {
+	return func(entry *LogEntry) {
+		entry.Hash = hash
+	}
+}
+
+func logErr(err error) logField {
+	return func(entry *LogEntry) {
+		entry.Err = err
+	}
+}
+
+type logger struct {
+	messages Messages
+	sink     LogSink
+	level    Level
+	mu       sync.RWMutex
+}
+
+func (aero *Aero) SetLogSink(sink LogSink) {
+	aero.logger.mu.Lock()
+	defer aero.logger.mu.Unlock()
+	aero.logger.sink = sink
+}
+
+func (aero *Aero) SetLogLevel(level Level) {
+	aero.logger.mu.Lock()
+	defer aero.logger.mu.Unlock()
+	aero.logger.level = level
+}
+
+func (aero *Aero) Logs() []LogEntry {
+	if messages, ok := aero.logger.messages.(*AeroMessages); ok {
+		return messages.Entries()
+	}
+	return []LogEntry{}
+}
+
+func (l *logger) log(level Level, component string, message string, fields ...logField) {
+	if l == nil {
+		return
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if level < l.level {
+		return
+	}
+
+	entry := LogEntry{Time: time.Now(), Level: level, Component: component, Message: message}
+	for _, field := range fields {
+		field(&entry)
+	}
+	if sink, ok := l.messages.(LogSink); ok {
+		sink.Log(entry)
+	} else if l.messages != nil {
+		l.messages.Add(entry.String(), entry.messageType())
+	}
+	if l.sink != nil {
+		l.sink.Log(entry)
+	}
+}
+
+func (l *logger) debug(component string, message string, fields ...logField) {
+	l.log(LevelDebug, component, message, fields...)
+}
+
+func (l *logger) info(component string, message string, fields ...logField) {
+	l.log(LevelInfo, component, message, fields...)
+}
+
+func (l *logger) warn(component string, message string, fields ...logField) {
+	l.log(LevelWarn, component, message, fields...)
+}
+
+func (l *logger) error(component string, message string, fields ...logField) {
+	l.log(LevelError, component, message, fields...)
+}
+
+func (aero *Aero) logServer(level int, message string, peer *api.Device, err error) {
+	fields := []logField{logErr(err)}
+	if peer != nil {
+		fields = append(fields, logPeer(Device{Name: peer.Name, Ip: peer.Ip}))
+	}
+	aero.logger.log(Level(level), "grpc", message, fields...)
+}
+
+func (s *SocketServer) log(level Level, component string, message string, fields ...logField) {
+	if s.logger != nil {
+		s.logger.log(level, component, message, fields...)
+		return
+	}
+	if s.Messages != nil {
+		(&logger{messages: s.Messages}).log(level, component, message, fields...)
+	}
+}
@@ -1,6 +1,9 @@
 package aero
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 const (
 	ERR = "ERROR"
@@ -8,6 +11,8 @@ const (
 	MSG = "MSG"
 )
 
+const defaultMessageLimit = 1000
+
 type Message struct {
 	Time   int64
 	Type   string
@@ -20,17 +25,69 @@ type Messages interface {
 }
 
 type AeroMessages struct {
-	messages []Message
+	Limit   int
+	entries []LogEntry
+	next    int
+	full    bool
+	mu      sync.Mutex
 }
 
 func (a *AeroMessages) Add(msg string, msgType string) {
-	a.messages = append(a.messages, Message{
-		Time:   time.Now().Unix(),
-		Type:   msgType,
-		String: msg,
-	})
+	a.Log(LogEntry{Time: time.Now(), Level: levelFromType(msgType), Message: msg})
+}
+
+func (a *AeroMessages) Log(entry LogEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	limit := a.Limit
+	if limit <= 0 {
+		limit = defaultMessageLimit
+	}
+	if len(a.entries) != limit {
+		a.resize(limit)
+	}
+	a.entries[a.next] = entry
+	a.next = (a.next + 1) % limit
+	a.full = a.full || a.next == 0
 }
 
 func (a *AeroMessages) Get() *[]Message {
-	return &a.messages
+	entries := a.Entries()
+	messages := make([]Message, 0, len(entries))
+	for _, entry := range entries {
+		messages = append(messages, Message{Time: entry.Time.Unix(), Type: entry.messageType(), String: entry.String()})
+	}
+	return &messages
+}
+
+func (a *AeroMessages) Entries() []LogEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.full {
+		return append([]LogEntry{}, a.entries[:a.next]...)
+	}
+	return append(append([]LogEntry{}, a.entries[a.next:]...), a.entries[:a.next]...)
+}
+
+func (a *AeroMessages) resize(limit int) {
+	entries := a.entries[:a.next]
+	if a.full {
+		entries = append(append([]LogEntry{}, a.entries[a.next:]...), a.entries[:a.next]...)
+	}
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	a.entries = make([]LogEntry, limit)
+	a.next = copy(a.entries, entries) % limit
+	a.full = len(entries) == limit
+}
+
+func levelFromType(msgType string) Level {
+	switch msgType {
+	case ERR:
+		return LevelError
+	case WRN:
+		return LevelWarn
+	}
+	return LevelInfo
 }
@@ -0,0 +1,118 @@
+package aero
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type CollisionPolicy int
+
+const (
+	Overwrite CollisionPolicy = iota
+	Rename
+	Skip
+)
+
+const partialSuffix = ".part"
+
+var errFileExists = errors.New("file already exists")
+
+type DownloadOptions struct {
+	Dir       string
+	Collision CollisionPolicy
+	TempFile  bool
+}
+
+func (aero *Aero) SetDownloadOptions(opts DownloadOptions) {
+	aero.SocketServer.configMu.Lock()
+	defer aero.SocketServer.configMu.Unlock()
+	aero.SocketServer.options = opts
+}
+
+func (s *SocketServer) downloadOptions() DownloadOptions {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.options
+}
+
+func (s *SocketServer) prepareTarget(progressWriter *ProgressWriter, target string, file File) bool {
+	target, err := s.resolveTarget(target)
+	if err == errFileExists && NewFile(target).Hash == file.Hash {
+		s.log(LevelInfo, "download", target+" already exists with the same content", logHash(file.Hash))
+		progressWriter.path = target
+		progressWriter.state = Done
+		progressWriter.complete()
+		return false
+	}
+	if err != nil {
+		progressWriter.fail(fmt.Errorf("%s: %s", target, err.Error()))
+		progressWriter.state = Failed
+		return false
+	}
+
+	progressWriter.path = target
+	progressWriter.target = target
+	if s.downloadOptions().TempFile {
+		progressWriter.target = target + partialSuffix
+	}
+	return true
+}
+
+func (s *SocketServer) resolveTarget(target string) (string, error) {
+	_, err := os.Stat(target)
+	if errors.Is(err, fs.ErrNotExist) && !s.reserved(target) {
+		return target, nil
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return target, err
+	}
+
+	switch s.downloadOptions().Collision {
+	case Skip:
+		return target, errFileExists
+	case Rename:
+		ext := filepath.Ext(target)
+		base := strings.TrimSuffix(target, ext)
+		for i := 1; ; i++ {
+			candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+			if _, err := os.Stat(candidate); errors.Is(err, fs.ErrNotExist) && !s.reserved(candidate) {
+				return candidate, nil
+			}
+		}
+	}
+	if s.reserved(target) {
+		return target, fmt.Errorf("already being downloaded")
+	}
+	return target, nil
+}
+
+func (s *SocketServer) defaultTarget(name string) string {
+	return filepath.Join(s.downloadOptions().Dir, safeName(name))
+}
+
+func safeName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == ".." || name == "/" {
+		return "download"
+	}
+	// a colon would make a drive relative path on windows
+	return strings.ReplaceAll(name, ":", "_")
+}
+
+func safeRelPath(rel string) (string, error) {
+	rel = strings.ReplaceAll(rel, "\\", "/")
+	if strings.HasPrefix(rel, "/") {
+		return "", fmt.Errorf("absolute path %s not allowed", rel)
+	}
+	for _, part := range strings.Split(rel, "/") {
+		if part == "" || part == "." || part == ".." || strings.Contains(part, ":") {
+			return "", fmt.Errorf("unsafe path %s", rel)
+		}
+	}
+	return filepath.FromSlash(rel), nil
+}
@@ -0,0 +1,82 @@
+package aero
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSkippedDownloadKeepsError(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(existing, []byte("local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &SocketServer{options: DownloadOptions{Dir: dir, Collision: Skip}}
+	d := Device{Name: "peer", Files: []File{{Name: "file.txt", Hash: "remote", Size: 6}}}
+	id := s.DownloadTo(d, 0, existing)
+	status, err := s.DownloadStatus(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.State != Failed || status.Error == nil {
+		t.Fatalf("want failed with an error, have %s %v", status.State, status.Error)
+	}
+}
+
+func TestSafeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"file.txt", "file.txt"},
+		{"../x", "x"},
+		{"/abs", "abs"},
+		{"/", "download"},
+		{"a/../../b", "b"},
+		{"C:foo", "C_foo"},
+		{"C:\\Windows\\system.ini", "system.ini"},
+		{"a\\..\\b", "b"},
+		{"..", "download"},
+		{".", "download"},
+		{"", "download"},
+	}
+	for _, test := range tests {
+		if have := safeName(test.name); have != test.want {
+			t.Errorf("safeName(%q): want %q, have %q", test.name, test.want, have)
+		}
+	}
+}
+
+func TestSafeRelPath(t *testing.T) {
+	tests := []struct {
+		rel  string
+		want string
+		ok   bool
+	}{
+		{"file.txt", "file.txt", true},
+		{"dir/sub/file.txt", filepath.Join("dir", "sub", "file.txt"), true},
+		{"dir\\file.txt", filepath.Join("dir", "file.txt"), true},
+		{"../x", "", false},
+		{"/abs", "", false},
+		{"\\abs", "", false},
+		{"a/../../b", "", false},
+		{"a/./b", "", false},
+		{"C:foo", "", false},
+		{"dir/C:foo", "", false},
+		{"a\\..\\b", "", false},
+		{"a//b", "", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		have, err := safeRelPath(test.rel)
+		if (err == nil) != test.ok {
+			t.Errorf("safeRelPath(%q): want ok %v, have error %v", test.rel, test.ok, err)
+			continue
+		}
+		if have != test.want {
+			t.Errorf("safeRelPath(%q): want %q, have %q", test.rel, test.want, have)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package aero
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	_ "google.golang.org/grpc/health"
+	"google.golang.org/grpc/keepalive"
+)
+
+const (
+	keepaliveTime    = 30 * time.Second
+	keepaliveTimeout = 10 * time.Second
+	healthService    = "/grpc.health.v1.Health/"
+	serviceConfig    = `{"loadBalancingConfig": [{"round_robin": {}}], "healthCheckConfig": {"serviceName": ""}}`
+)
+
+type pooledConn struct {
+	addr string
+	conn *grpc.ClientConn
+}
+
+type connPool struct {
+	mu    sync.Mutex
+	conns map[string]pooledConn
+}
+
+func (p *connPool) get(d Device, dial func(addr string) (*grpc.ClientConn, error)) (*grpc.ClientConn, error) {
+	key := poolKey(d)
+	addr := net.JoinHostPort(d.Ip, d.Port)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conns == nil {
+		p.conns = make(map[string]pooledConn)
+	}
+	if pc, ok := p.conns[key]; ok {
+		state := pc.conn.GetState()
+		if pc.addr == addr && state != connectivity.TransientFailure && state != connectivity.Shutdown {
+			return pc.conn, nil
+		}
+		pc.conn.Close()
+		delete(p.conns, key)
+	}
+	conn, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[key] = pooledConn{addr: addr, conn: conn}
+	return conn, nil
+}
+
+func (p *connPool) retain(devices []Device) {
+	keep := make(map[string]bool)
+	for _, d := range devices {
+		keep[poolKey(d)] = true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, pc := range p.conns {
+		if !keep[key] {
+			pc.conn.Close()
+			delete(p.conns, key)
+		}
+	}
+}
+
+func (p *connPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, pc := range p.conns {
+		pc.conn.Close()
+		delete(p.conns, key)
+	}
+}
+
+func poolKey(d Device) string {
+	if len(d.Hash) > 0 {
+		return d.Hash
+	}
+	return d.Ip + ":" + d.Port
+}
+
+func clientKeepalive() grpc.DialOption {
+	return grpc.WithKeepaliveParams(keepalive.ClientParameters{Time: keepaliveTime, Timeout: keepaliveTimeout, PermitWithoutStream: true})
+}
+
+func serverKeepalive() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: keepaliveTime / 2, PermitWithoutStream: true}),
+		grpc.KeepaliveParams(keepalive.ServerParameters{Time: keepaliveTime, Timeout: keepaliveTimeout}),
+	}
+}
+
+func isHealthCheck(method string) bool {
+	return strings.HasPrefix(method, healthService)
+}
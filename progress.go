@@ -0,0 +1,103 @@
+package aero
+
+import (
+	"time"
+)
+
+const defaultProgressInterval = time.Second
+
+type TransferKind int
+
+const (
+	DownloadTransfer TransferKind = iota
+	UploadTransfer
+)
+
+type ProgressEvent struct {
+	Kind         TransferKind
+	Id           int
+	Name         string
+	Hash         string
+	Device       Device
+	State        DownloadState
+	Transferred  int64
+	Total        int64
+	Speed        float64
+	AverageSpeed float64
+	ETA          time.Duration
+	Error        error
+}
+
+type ProgressHandler func(event ProgressEvent)
+
+func (aero *Aero) SetProgressHandler(handler ProgressHandler, interval time.Duration) {
+	aero.SocketServer.configMu.Lock()
+	defer aero.SocketServer.configMu.Unlock()
+	aero.SocketServer.progressHandler = handler
+	aero.SocketServer.progressInterval = interval
+}
+
+func (s *SocketServer) trackProgress(event ProgressEvent, progressWriter *ProgressWriter) func(state DownloadState) {
+	s.configMu.RLock()
+	handler, interval := s.progressHandler, s.progressInterval
+	s.configMu.RUnlock()
+	if handler == nil {
+		return func(DownloadState) {}
+	}
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+
+	stop := make(chan DownloadState)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		start := time.Now()
+		last := start
+		first, _, _ := progressWriter.snapshot()
+		previous := first
+		event.State = Running
+		for {
+			final := false
+			select {
+			case <-ticker.C:
+			case event.State = <-stop:
+				final = true
+			}
+
+			now := time.Now()
+			transferred, total, err := progressWriter.snapshot()
+			event.Transferred = transferred
+			event.Total = total
+			event.Speed = float64(transferred-previous) / now.Sub(last).Seconds()
+			event.AverageSpeed = float64(transferred-first) / now.Sub(start).Seconds()
+			event.ETA = 0
+			if event.AverageSpeed > 0 && total > transferred {
+				event.ETA = time.Duration(float64(total-transferred) / event.AverageSpeed * float64(time.Second))
+			}
+			if final {
+				event.Error = err
+			}
+			handler(event)
+			if final {
+				return
+			}
+			previous = transferred
+			last = now
+		}
+	}()
+
+	return func(state DownloadState) {
+		stop <- state
+		<-done
+	}
+}
+
+func (pw *ProgressWriter) snapshot() (int64, int64, error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	return pw.Received, pw.FileSize, pw.Error
+}
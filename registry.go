@@ -0,0 +1,173 @@
+package aero
+
+import (
+	"sync"
+)
+
+type registry struct {
+	mu       sync.RWMutex
+	devices  []Device
+	self     Device
+	master   bool
+	term     int64
+	changes  chan struct{}
+	replaced func(devices []Device)
+	departed func(d Device)
+	left     bool
+}
+
+func newRegistry(self Device, master bool) *registry {
+	return &registry{devices: []Device{copyDevice(self)}, self: copyDevice(self), master: master, changes: make(chan struct{}, 1)}
+}
+
+func (aero *Aero) Devices() []Device {
+	return aero.registry.snapshot()
+}
+
+func (aero *Aero) Self() Device {
+	return aero.registry.getSelf()
+}
+
+func (aero *Aero) IsMaster() bool {
+	return aero.registry.isMaster()
+}
+
+func (aero *Aero) Changes() <-chan struct{} {
+	return aero.registry.changes
+}
+
+func (r *registry) snapshot() []Device {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Device, 0, len(r.devices))
+	for _, d := range r.devices {
+		out = append(out, copyDevice(d))
+	}
+	return out
+}
+
+func (r *registry) replace(devices []Device) {
+	r.update(func([]Device) []Device { return devices })
+}
+
+func (r *registry) remove(d Device) {
+	r.update(func(current []Device) []Device {
+		devices := make([]Device, 0, len(current))
+		for _, device := range current {
+			if !sameDevice(device, d) {
+				devices = append(devices, device)
+			}
+		}
+		return devices
+	})
+}
+
+func (r *registry) update(change func(current []Device) []Device) {
+	r.mu.Lock()
+	devices := change(r.devices)
+	gone := make([]Device, 0)
+	for _, old := range r.devices {
+		found := false
+		for _, d := range devices {
+			found = found || sameDevice(old, d)
+		}
+		if !found && !sameDevice(old, r.self) {
+			gone = append(gone, old)
+		}
+	}
+	r.devices = make([]Device, 0, len(devices))
+	for _, d := range devices {
+		r.devices = append(r.devices, copyDevice(d))
+	}
+	replaced, departed := r.replaced, r.departed
+	r.mu.Unlock()
+	if replaced != nil {
+		replaced(devices)
+	}
+	if departed != nil {
+		for _, d := range gone {
+			departed(d)
+		}
+	}
+	r.notify()
+}
+
+func (r *registry) masterDevice() Device {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.devices) == 0 {
+		return Device{}
+	}
+	return copyDevice(r.devices[0])
+}
+
+func (r *registry) getSelf() Device {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return copyDevice(r.self)
+}
+
+func (r *registry) setSelf(d Device) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.self = copyDevice(d)
+}
+
+func (r *registry) updateSelf(update func(self *Device) error) (Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	self := copyDevice(r.self)
+	if err := update(&self); err != nil {
+		return copyDevice(r.self), err
+	}
+	r.self = self
+	return copyDevice(self), nil
+}
+
+func (r *registry) isMaster() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.master
+}
+
+func (r *registry) setMaster(master bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.master = master
+}
+
+func (r *registry) getTerm() int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.term
+}
+
+func (r *registry) setTerm(term int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.term = term
+}
+
+func (r *registry) hasLeft() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.left
+}
+
+func (r *registry) setLeft(left bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.left = left
+}
+
+func (r *registry) notify() {
+	select {
+	case r.changes <- struct{}{}:
+	default:
+	}
+}
+
+func copyDevice(d Device) Device {
+	d.Files = append([]File(nil), d.Files...)
+	return d
+}
@@ -1,165 +1,590 @@
 package aero
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhamith93/aero/internal/auth"
+)
+
+const (
+	protocolVersion = 1
+	maxRequestSize  = 4096
+	maxChunkRetries = 3
+	lingerTimeout   = time.Minute
 )
 
+const actionUpload = "upload"
+
+type fileRequest struct {
+	Version     int    `json:"version"`
+	Action      string `json:"action,omitempty"`
+	Hash        string `json:"hash"`
+	Offset      int64  `json:"offset,omitempty"`
+	Length      int64  `json:"length,omitempty"`
+	Ticket      string `json:"ticket,omitempty"`
+	Compression string `json:"compression,omitempty"`
+}
+
+func writeRequest(w io.Writer, req fileRequest) error {
+	req.Version = protocolVersion
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func readRequest(r *bufio.Reader) (fileRequest, error) {
+	req := fileRequest{}
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return req, fmt.Errorf("request header too large")
+	}
+	if err != nil {
+		return req, err
+	}
+	if err := json.Unmarshal(line, &req); err != nil {
+		return req, err
+	}
+	if req.Version != protocolVersion {
+		return req, fmt.Errorf("unsupported protocol version %d", req.Version)
+	}
+	if req.Offset < 0 || req.Length < 0 {
+		return req, fmt.Errorf("invalid range %d+%d", req.Offset, req.Length)
+	}
+	return req, nil
+}
+
 type ProgressWriter struct {
 	FileSize    int64
 	Received    int64
 	Progress    int
 	HashMatched bool
 	Error       error
+	device      Device
+	fileIdx     int
+	path        string
+	target      string
+	sources     []Device
+	id          int
+	key         string
+	started     time.Time
+	state       DownloadState
+	offset      int64
+	stop        chan struct{}
+	ctx         context.Context
+	mu          sync.Mutex
 }
 
 func (pw *ProgressWriter) Write(data []byte) (int, error) {
-	pw.Received += int64(len(data))
-	pw.Progress = int((pw.Received * 100) / pw.FileSize)
-	return pw.Progress, nil
+	pw.add(int64(len(data)))
+	return len(data), nil
+}
+
+func (pw *ProgressWriter) add(n int64) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.Received += n
+	if pw.FileSize > 0 {
+		pw.Progress = int((pw.Received * 100) / pw.FileSize)
+	}
 }
 
 type SocketServer struct {
-	Port      string
-	Devices   *[]Device
-	Self      *Device
-	server    net.Listener
-	Messages  Messages
-	Downloads map[int]*ProgressWriter
+	Port             string
+	registry         *registry
+	server           net.Listener
+	serverMu         sync.Mutex
+	closing          bool
+	conns            map[net.Conn]Device
+	Messages         Messages
+	uploads          map[int]*ProgressWriter
+	uploadsMu        sync.Mutex
+	lastUpload       int
+	offers           map[string]offer
+	reserveMu        sync.Mutex
+	options          DownloadOptions
+	compression      bool
+	bandwidth        *bandwidth
+	mu               sync.Mutex
+	downloads        map[int]*ProgressWriter
+	downloadsMu      sync.Mutex
+	lastDownload     int
+	maxDownloads     int
+	progressHandler  ProgressHandler
+	progressInterval time.Duration
+	journal          Journal
+	logger           *logger
+	chunks           func(ctx context.Context, d Device, hash string) ([]string, error)
+	ticket           func(ctx context.Context, d Device, hash string) (string, bool, error)
+	key              string
+	serverTLS        *tls.Config
+	clientTLS        *tls.Config
+	configMu         sync.RWMutex
+}
+
+func (s *SocketServer) configure(port string, key string, chunks func(ctx context.Context, d Device, hash string) ([]string, error), ticket func(ctx context.Context, d Device, hash string) (string, bool, error), serverTLS *tls.Config, clientTLS *tls.Config) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	s.Port = port
+	s.key = key
+	s.chunks = chunks
+	s.ticket = ticket
+	s.serverTLS = serverTLS
+	s.clientTLS = clientTLS
 }
 
 func (s *SocketServer) Start() error {
-	var err error
-	s.server, err = net.Listen("tcp", "0.0.0.0"+":"+s.Port)
+	s.configMu.RLock()
+	port, serverTLS := s.Port, s.serverTLS
+	s.configMu.RUnlock()
+	listener, err := net.Listen("tcp", ":"+port)
 	if err != nil {
 		return err
 	}
-	defer s.server.Close()
-	s.Downloads = make(map[int]*ProgressWriter)
+	if serverTLS != nil {
+		listener = tls.NewListener(listener, serverTLS)
+	}
+	s.serverMu.Lock()
+	if s.closing {
+		s.serverMu.Unlock()
+		listener.Close()
+		return net.ErrClosed
+	}
+	s.server = listener
+	s.serverMu.Unlock()
+	defer listener.Close()
 	for {
-		connection, err := s.server.Accept()
+		connection, err := listener.Accept()
 		if err != nil {
 			return err
 		}
-		go s.handleFileRequest(connection)
+		if !s.track(connection, Device{}) {
+			connection.Close()
+			continue
+		}
+		go func() {
+			defer s.untrack(connection)
+			s.handleFileRequest(connection)
+		}()
 	}
 }
 
 func (s *SocketServer) Stop() {
-	s.server.Close()
+	s.serverMu.Lock()
+	defer s.serverMu.Unlock()
+	s.closing = true
+	if s.server != nil {
+		s.server.Close()
+	}
+}
+
+func (s *SocketServer) track(connection net.Conn, peer Device) bool {
+	s.serverMu.Lock()
+	defer s.serverMu.Unlock()
+	if s.closing {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]Device)
+	}
+	s.conns[connection] = peer
+	return true
+}
+
+func (s *SocketServer) identify(connection net.Conn, peer Device) {
+	s.serverMu.Lock()
+	defer s.serverMu.Unlock()
+	if _, ok := s.conns[connection]; ok {
+		s.conns[connection] = peer
+	}
+}
+
+func (s *SocketServer) untrack(connection net.Conn) {
+	s.serverMu.Lock()
+	defer s.serverMu.Unlock()
+	delete(s.conns, connection)
+}
+
+func (s *SocketServer) stopping() bool {
+	s.serverMu.Lock()
+	defer s.serverMu.Unlock()
+	return s.closing
+}
+
+func (s *SocketServer) dial(ctx context.Context, d Device) (net.Conn, error) {
+	s.configMu.RLock()
+	clientTLS := s.clientTLS
+	s.configMu.RUnlock()
+	addr := net.JoinHostPort(d.Ip, d.SocketPort)
+	if clientTLS != nil {
+		dialer := &tls.Dialer{Config: clientTLS}
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+	dialer := &net.Dialer{}
+	return dialer.DialContext(ctx, "tcp", addr)
 }
 
 func (s *SocketServer) handleFileRequest(connection net.Conn) {
 	defer connection.Close()
-	s.Messages.Add("send_file: serving client: "+connection.RemoteAddr().String(), MSG)
-	remoteAddr := strings.Split(connection.RemoteAddr().String(), ":")
-
-	if len(remoteAddr) < 2 {
-		s.Messages.Add("send_file: cannot parse remote address to verification", ERR)
+	s.log(LevelDebug, "send_file", "serving client "+connection.RemoteAddr().String())
+	remoteIp, _, err := net.SplitHostPort(connection.RemoteAddr().String())
+	if err != nil {
+		s.log(LevelError, "send_file", "cannot parse remote address to verification", logErr(err))
 		return
 	}
 
 	found := false
-	for _, device := range *s.Devices {
-		if device.Ip == remoteAddr[0] {
+	peer := Device{}
+	for _, device := range s.registry.snapshot() {
+		if device.Ip == remoteIp {
 			found = true
+			peer = device
 		}
 	}
 
 	if !found {
-		s.Messages.Add("send_file: incoming device not found in list "+remoteAddr[0], ERR)
+		s.log(LevelError, "send_file", "incoming device not found in list", logAddr(remoteIp))
 		return
 	}
+	s.identify(connection, peer)
 
 	found = false
-	buffer := make([]byte, 1024)
-	mLen, err := connection.Read(buffer)
+	reader := bufio.NewReaderSize(connection, maxRequestSize)
+	request, err := readRequest(reader)
 	if err != nil {
-		s.Messages.Add("send_file: "+err.Error(), ERR)
+		s.log(LevelError, "send_file", "invalid request", logPeer(peer), logErr(err))
+		return
+	}
+
+	s.configMu.RLock()
+	key := s.key
+	s.configMu.RUnlock()
+	if !auth.ValidTicket(request.Ticket, key, request.Hash, remoteIp) {
+		s.log(LevelError, "send_file", "rejected request without a valid ticket", logPeer(peer), logHash(request.Hash))
+		return
+	}
+
+	if request.Action == actionUpload {
+		s.receiveUpload(connection, reader, request)
+		return
+	}
+
+	if !s.acceptsCompression(request.Compression) {
+		s.log(LevelError, "send_file", "refused "+request.Compression+" compression while compression is disabled", logPeer(peer), logHash(request.Hash))
 		return
 	}
 
-	requestedFileHash := string(buffer[:mLen])
 	outputFile := File{}
 
-	for _, file := range s.Self.Files {
-		if file.Hash == requestedFileHash {
+	for _, file := range s.registry.getSelf().Files {
+		if file.Hash == request.Hash {
 			found = true
 			outputFile = file
 		}
 	}
 
 	if !found {
-		s.Messages.Add("send_file: requested file not found in list "+requestedFileHash, ERR)
+		s.log(LevelError, "send_file", "requested file not found in list", logPeer(peer), logHash(request.Hash))
+		return
+	}
+
+	if request.Offset > outputFile.Size {
+		s.log(LevelError, "send_file", fmt.Sprintf("offset %d out of bound for %s", request.Offset, outputFile.Name), logPeer(peer), logHash(request.Hash))
 		return
 	}
 
+	sent := false
+	// deferred first so it runs after the file is closed and the upload slot is released
+	defer func() {
+		if sent {
+			linger(connection, reader)
+		}
+	}()
+
 	file, err := os.Open(strings.TrimSpace(outputFile.Path))
 	if err != nil {
-		s.Messages.Add("send_file: "+err.Error(), ERR)
+		s.log(LevelError, "send_file", "cannot send "+outputFile.Name, logPeer(peer), logHash(request.Hash), logErr(err))
 		return
 	}
 	defer file.Close()
 
-	s.Messages.Add("send_file: sending "+outputFile.Name, MSG)
-	_, err = io.Copy(connection, file)
+	if _, err := file.Seek(request.Offset, io.SeekStart); err != nil {
+		s.log(LevelError, "send_file", "cannot send "+outputFile.Name, logPeer(peer), logHash(request.Hash), logErr(err))
+		return
+	}
+
+	s.bandwidth.acquireUpload()
+	defer s.bandwidth.releaseUpload()
+
+	out, err := compressWriter(s.bandwidth.writer(connection, remoteIp), request.Compression)
+	if err != nil {
+		s.log(LevelError, "send_file", "cannot send "+outputFile.Name, logPeer(peer), logHash(request.Hash), logErr(err))
+		return
+	}
+
+	length := outputFile.Size - request.Offset
+	if request.Length > 0 && request.Length < length {
+		length = request.Length
+	}
+	progressWriter := &ProgressWriter{FileSize: length, device: peer}
+	finish := s.trackProgress(ProgressEvent{Kind: UploadTransfer, Name: outputFile.Name, Hash: outputFile.Hash, Device: peer}, progressWriter)
+
+	s.log(LevelInfo, "send_file", fmt.Sprintf("sending %s from offset %d", outputFile.Name, request.Offset), logPeer(peer), logHash(request.Hash))
+	_, err = io.CopyN(out, io.TeeReader(file, progressWriter), length)
+	if err == nil {
+		err = out.Close()
+	}
 	if err != nil {
-		s.Messages.Add("send_file: "+err.Error(), ERR)
+		s.log(LevelError, "send_file", "cannot send "+outputFile.Name, logPeer(peer), logHash(request.Hash), logErr(err))
+		progressWriter.fail(err)
+		finish(Failed)
+		return
 	}
+	finish(Done)
+	sent = true
+}
+
+// stay tracked until the peer hangs up so a drain covers data still in flight
+func linger(connection net.Conn, reader io.Reader) {
+	connection.SetReadDeadline(time.Now().Add(lingerTimeout))
+	io.Copy(io.Discard, reader)
 }
 
 func (s *SocketServer) Download(d Device, fileIdx int) int {
-	if s.Downloads == nil {
-		s.Downloads = make(map[int]*ProgressWriter)
+	return s.DownloadContext(context.Background(), d, fileIdx)
+}
+
+func (s *SocketServer) DownloadContext(ctx context.Context, d Device, fileIdx int) int {
+	return s.DownloadToContext(ctx, d, fileIdx, s.defaultTarget(d.Files[fileIdx].Name))
+}
+
+func (s *SocketServer) DownloadTo(d Device, fileIdx int, path string) int {
+	return s.DownloadToContext(context.Background(), d, fileIdx, path)
+}
+
+func (s *SocketServer) DownloadToContext(ctx context.Context, d Device, fileIdx int, path string) int {
+	progressWriter := &ProgressWriter{FileSize: d.Files[fileIdx].Size, device: d, fileIdx: fileIdx, ctx: ctx}
+	return s.addDownload(progressWriter, s.prepareTarget(progressWriter, path, d.Files[fileIdx]))
+}
+
+func (s *SocketServer) DownloadDirectory(d Device, dir string, root string) []int {
+	return s.DownloadDirectoryContext(context.Background(), d, dir, root)
+}
+
+func (s *SocketServer) DownloadDirectoryContext(ctx context.Context, d Device, dir string, root string) []int {
+	if len(root) == 0 {
+		root = s.downloadOptions().Dir
+	}
+	ids := make([]int, 0)
+	for i, f := range d.Files {
+		if len(f.RelPath) == 0 || (len(dir) > 0 && f.RelPath != dir && !strings.HasPrefix(f.RelPath, dir+"/")) {
+			continue
+		}
+		rel, err := safeRelPath(f.RelPath)
+		if err != nil {
+			s.log(LevelWarn, "download", "skipping file", logPeer(d), logHash(f.Hash), logErr(err))
+			continue
+		}
+		ids = append(ids, s.DownloadToContext(ctx, d, i, filepath.Join(root, rel)))
 	}
+	return ids
+}
 
-	id := len(s.Downloads) + 1
-	s.Downloads[id] = &ProgressWriter{FileSize: d.Files[fileIdx].Size}
-	go s.download(d, fileIdx, id)
-	return id
+func (s *SocketServer) Resume(downloadId int) error {
+	progressWriter, err := s.getDownload(downloadId)
+	if err != nil {
+		return err
+	}
+
+	progressWriter.mu.Lock()
+	switch {
+	case progressWriter.HashMatched:
+		err = fmt.Errorf("download %d already completed", downloadId)
+	case progressWriter.state != Paused && progressWriter.state != Failed:
+		err = fmt.Errorf("download %d is %s", downloadId, progressWriter.state)
+	case len(progressWriter.target) == 0:
+		err = fmt.Errorf("download %d cannot be resumed", downloadId)
+	}
+	if err != nil {
+		progressWriter.mu.Unlock()
+		return err
+	}
+
+	var offset, received int64
+	info, err := os.Stat(progressWriter.target)
+	switch {
+	case err != nil:
+	case len(progressWriter.sources) > 1:
+		// swarm targets are checked chunk by chunk when they restart
+		offset = info.Size()
+	case info.Size() < progressWriter.FileSize:
+		offset = info.Size() - info.Size()%chunkSize
+		received = offset
+	}
+
+	progressWriter.Error = nil
+	progressWriter.offset = offset
+	progressWriter.Received = received
+	progressWriter.Progress = 0
+	if progressWriter.FileSize > 0 {
+		progressWriter.Progress = int((received * 100) / progressWriter.FileSize)
+	}
+	progressWriter.state = Queued
+	progressWriter.mu.Unlock()
+	s.recordDownload(progressWriter)
+	s.schedule()
+	return nil
 }
 
-func (s *SocketServer) download(d Device, fileIdx int, downloadId int) {
-	progressWriter := s.Downloads[downloadId]
-	connection, err := net.Dial("tcp", d.Ip+":"+d.SocketPort)
+func (s *SocketServer) download(d Device, fileIdx int, progressWriter *ProgressWriter, offset int64) {
+	file := d.Files[fileIdx]
+	chunks := s.fetchChunks(progressWriter.context(), d, file.Hash)
+
+	ticket, remoteCompression, err := s.requestTicket(progressWriter.context(), d, file.Hash)
+	if err != nil {
+		progressWriter.fail(err)
+		return
+	}
+
+	connection, err := s.dial(progressWriter.context(), d)
 	if err != nil {
-		progressWriter.Error = err
+		progressWriter.fail(err)
 		return
 	}
 	defer connection.Close()
+	defer progressWriter.watch(connection)()
 
-	_, err = connection.Write([]byte(d.Files[fileIdx].Hash))
+	compression := s.compressionFor(file, remoteCompression)
+	err = writeRequest(connection, fileRequest{Hash: file.Hash, Offset: offset, Ticket: ticket, Compression: compression})
 	if err != nil {
-		progressWriter.Error = err
+		progressWriter.fail(err)
 		return
 	}
 
-	newFile, err := os.Create(d.Files[fileIdx].Name)
+	reader, err := decompressReader(s.bandwidth.reader(connection, d.Ip), compression)
 	if err != nil {
-		progressWriter.Error = err
+		progressWriter.fail(err)
+		return
+	}
+
+	newFile, err := createTarget(progressWriter.target, offset == 0)
+	if err != nil {
+		progressWriter.fail(err)
 		return
 	}
 	defer newFile.Close()
 
-	rdr := io.TeeReader(connection, progressWriter)
+	writers := []io.Writer{&offsetWriter{file: newFile, offset: offset}, progressWriter}
+	var verifier *chunkVerifier
+	if chunks != nil {
+		verifier = newChunkVerifier(chunks, file.Size, offset)
+		writers = append(writers, verifier)
+	}
 
-	_, err = io.Copy(newFile, rdr)
+	_, err = io.Copy(io.MultiWriter(writers...), io.LimitReader(reader, file.Size-offset))
 	if err != nil {
-		progressWriter.Error = err
+		progressWriter.fail(err)
 		return
 	}
+	connection.Close()
+
+	if verifier != nil {
+		if err := s.repairChunks(d, file, chunks, verifier.Finish(), newFile, progressWriter); err != nil {
+			progressWriter.fail(err)
+			return
+		}
+	}
+
+	s.verifyDownload(d, file, progressWriter)
+}
+
+func (s *SocketServer) requestTicket(ctx context.Context, d Device, hash string) (string, bool, error) {
+	s.configMu.RLock()
+	ticket := s.ticket
+	s.configMu.RUnlock()
+	if ticket == nil {
+		return "", false, fmt.Errorf("socket server is not started")
+	}
+	return ticket(ctx, d, hash)
+}
+
+func (s *SocketServer) fetchChunks(ctx context.Context, d Device, hash string) []string {
+	s.configMu.RLock()
+	fetch := s.chunks
+	s.configMu.RUnlock()
+	if fetch == nil {
+		return nil
+	}
+	chunks, err := fetch(ctx, d, hash)
+	if err != nil {
+		s.log(LevelWarn, "download", "chunk manifest unavailable", logPeer(d), logHash(hash), logErr(err))
+		return nil
+	}
+	return chunks
+}
 
-	createdFile := NewFile(d.Files[fileIdx].Name)
-	if d.Files[fileIdx].Hash != createdFile.Hash {
-		err := fmt.Errorf("file transfer failed due to hash mismatch. want %s have %s", d.Files[fileIdx].Hash, createdFile.Hash)
-		progressWriter.Error = err
-		progressWriter.HashMatched = false
+func (s *SocketServer) repairChunks(d Device, file File, chunks []string, bad []byteRange, f *os.File, progressWriter *ProgressWriter) error {
+	for attempt := 0; len(bad) > 0; attempt++ {
+		if attempt == maxChunkRetries {
+			return fmt.Errorf("%d chunks still corrupt after %d attempts", len(bad), maxChunkRetries)
+		}
+		s.log(LevelWarn, "download", fmt.Sprintf("re-requesting %d corrupt chunks of %s", len(bad), file.Name), logPeer(d), logHash(file.Hash))
+		retry := make([]byteRange, 0)
+		for _, r := range bad {
+			progressWriter.add(-r.length)
+			verifier := newChunkVerifier(chunks, file.Size, r.offset)
+			if err := s.fetchRange(d, file, r, f, progressWriter, verifier); err != nil {
+				return err
+			}
+			retry = append(retry, verifier.Finish()...)
+		}
+		bad = retry
+	}
+	return nil
+}
+
+func createTarget(path string, truncate bool) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if truncate {
+		return os.Create(path)
+	}
+	return os.OpenFile(path, os.O_RDWR, 0644)
+}
+
+func (s *SocketServer) verifyDownload(d Device, file File, progressWriter *ProgressWriter) {
+	progressWriter.setState(Verifying)
+	createdFile := NewFile(progressWriter.target)
+	if file.Hash != createdFile.Hash {
+		err := fmt.Errorf("file transfer failed due to hash mismatch. want %s have %s", file.Hash, createdFile.Hash)
+		progressWriter.fail(err)
+		return
+	}
+	if progressWriter.stopped() {
 		return
 	}
 
-	s.Messages.Add("received file: "+createdFile.Name+" from: "+d.Name+" "+d.Ip, MSG)
-	progressWriter.HashMatched = true
+	if progressWriter.target != progressWriter.path {
+		if err := os.Rename(progressWriter.target, progressWriter.path); err != nil {
+			progressWriter.fail(err)
+			return
+		}
+	}
+
+	s.log(LevelInfo, "download", "received file "+filepath.Base(progressWriter.path), logPeer(d), logHash(file.Hash))
+	progressWriter.complete()
 }
@@ -0,0 +1,29 @@
+package aero
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestDialStopsWithContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := &SocketServer{}
+	connection, err := s.dial(ctx, Device{Ip: "127.0.0.1", SocketPort: port})
+	if err == nil {
+		connection.Close()
+		t.Fatal("dial ignored a cancelled context")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, have %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package aero
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	drainInterval = 50 * time.Millisecond
+	leaveTimeout  = 2 * time.Second
+)
+
+func (aero *Aero) Shutdown(ctx context.Context) error {
+	aero.logger.info("shutdown", "shutting down")
+	aero.grpcMu.Lock()
+	if aero.health != nil {
+		aero.health.Shutdown()
+	}
+	aero.grpcMu.Unlock()
+	aero.SocketServer.Stop()
+	leaving := !aero.IsMaster() && !aero.registry.hasLeft()
+	if leaving {
+		if err := aero.announceLeaving(ctx); err != nil {
+			aero.logger.warn("shutdown", "cannot notify master", logPeer(aero.registry.masterDevice()), logErr(err))
+		}
+	}
+
+	err := aero.SocketServer.drain(ctx)
+	if err != nil {
+		aero.logger.warn("shutdown", "transfers did not finish in time, unfinished downloads are paused for resume", logErr(err))
+	}
+	// leave only after the drain, the master fails every transfer with a departed device
+	if leaving {
+		leaveCtx, cancel := context.WithTimeout(context.Background(), leaveTimeout)
+		aero.leave(leaveCtx)
+		cancel()
+	}
+	aero.stopGrpcServer(ctx)
+	aero.pool.close()
+	aero.logger.info("shutdown", "stopped")
+	return err
+}
+
+// stop advertising files so peers stop picking this device as a source
+func (aero *Aero) announceLeaving(ctx context.Context) error {
+	self := aero.registry.getSelf()
+	self.Files = nil
+	_, err := aero.refreshDevice(ctx, GenerateAPIDeviceFromDevice(&self))
+	return err
+}
+
+func (s *SocketServer) drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+	for !s.idle() {
+		select {
+		case <-ctx.Done():
+			s.checkpoint()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+func (s *SocketServer) idle() bool {
+	s.serverMu.Lock()
+	active := len(s.conns)
+	s.serverMu.Unlock()
+	if active > 0 {
+		return false
+	}
+
+	s.downloadsMu.Lock()
+	defer s.downloadsMu.Unlock()
+	for _, progressWriter := range s.downloads {
+		if state := progressWriter.getState(); state == Running || state == Verifying {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *SocketServer) checkpoint() {
+	s.downloadsMu.Lock()
+	running := make([]*ProgressWriter, 0)
+	for _, progressWriter := range s.downloads {
+		if state := progressWriter.getState(); state == Running || state == Verifying {
+			running = append(running, progressWriter)
+		}
+	}
+	s.downloadsMu.Unlock()
+
+	for _, progressWriter := range running {
+		if err := progressWriter.halt(Paused); err == nil {
+			s.recordDownload(progressWriter)
+		}
+	}
+
+	s.serverMu.Lock()
+	defer s.serverMu.Unlock()
+	for connection := range s.conns {
+		connection.Close()
+	}
+}
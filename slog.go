@@ -0,0 +1,38 @@
+//go:build go1.21
+
+package aero
+
+import (
+	"context"
+	"log/slog"
+)
+
+type slogSink struct {
+	handler slog.Handler
+}
+
+func NewSlogSink(handler slog.Handler) LogSink {
+	return slogSink{handler: handler}
+}
+
+func (s slogSink) Log(entry LogEntry) {
+	ctx := context.Background()
+	if !s.handler.Enabled(ctx, slog.Level(entry.Level)) {
+		return
+	}
+	record := slog.NewRecord(entry.Time, slog.Level(entry.Level), entry.Message, 0)
+	record.AddAttrs(slog.String("component", entry.Component))
+	if len(entry.Peer) > 0 {
+		record.AddAttrs(slog.String("peer", entry.Peer))
+	}
+	if len(entry.PeerName) > 0 {
+		record.AddAttrs(slog.String("peer_name", entry.PeerName))
+	}
+	if len(entry.Hash) > 0 {
+		record.AddAttrs(slog.String("hash", entry.Hash))
+	}
+	if entry.Err != nil {
+		record.AddAttrs(slog.Any("error", entry.Err))
+	}
+	s.handler.Handle(ctx, record)
+}
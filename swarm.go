@@ -0,0 +1,229 @@
+package aero
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+const swarmRangeSize = 4 * chunkSize
+
+type byteRange struct {
+	offset int64
+	length int64
+}
+
+type offsetWriter struct {
+	file   *os.File
+	offset int64
+}
+
+func (w *offsetWriter) Write(data []byte) (int, error) {
+	n, err := w.file.WriteAt(data, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
+
+func splitRanges(size int64, rangeSize int64) []byteRange {
+	ranges := make([]byteRange, 0)
+	for offset := int64(0); offset < size; offset += rangeSize {
+		length := rangeSize
+		if offset+length > size {
+			length = size - offset
+		}
+		ranges = append(ranges, byteRange{offset: offset, length: length})
+	}
+	return ranges
+}
+
+// missingRanges keeps the chunks already on disk that match the manifest and returns the rest
+func missingRanges(f *os.File, chunks []string, size int64, progressWriter *ProgressWriter) []byteRange {
+	ranges := make([]byteRange, 0)
+	for i, r := range splitRanges(size, chunkSize) {
+		hasher := newChunkHasher()
+		_, err := io.Copy(hasher, io.NewSectionReader(f, r.offset, r.length))
+		if sums := hasher.Sum(); err == nil && i < len(chunks) && len(sums) == 1 && sums[0] == chunks[i] {
+			progressWriter.add(r.length)
+			continue
+		}
+		last := len(ranges) - 1
+		if last >= 0 && ranges[last].offset+ranges[last].length == r.offset && ranges[last].length+r.length <= swarmRangeSize {
+			ranges[last].length += r.length
+			continue
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+func (s *SocketServer) SwarmDownload(d Device, fileIdx int) int {
+	return s.SwarmDownloadContext(context.Background(), d, fileIdx)
+}
+
+func (s *SocketServer) SwarmDownloadContext(ctx context.Context, d Device, fileIdx int) int {
+	file := d.Files[fileIdx]
+	progressWriter := &ProgressWriter{FileSize: file.Size, device: d, fileIdx: fileIdx, sources: s.sources(d, file.Hash), ctx: ctx}
+	return s.addDownload(progressWriter, s.prepareTarget(progressWriter, s.defaultTarget(file.Name), file))
+}
+
+func (s *SocketServer) sources(d Device, hash string) []Device {
+	sources := []Device{d}
+	self := s.registry.getSelf()
+	for _, device := range s.registry.snapshot() {
+		if device.Ip == d.Ip && device.SocketPort == d.SocketPort {
+			continue
+		}
+		if device.Ip == self.Ip && device.SocketPort == self.SocketPort {
+			continue
+		}
+		if !device.Active {
+			continue
+		}
+		for _, f := range device.Files {
+			if f.Hash == hash {
+				sources = append(sources, device)
+				break
+			}
+		}
+	}
+	return sources
+}
+
+func (s *SocketServer) swarmDownload(sources []Device, file File, progressWriter *ProgressWriter, resume bool) {
+	newFile, err := createTarget(progressWriter.target, !resume)
+	if err != nil {
+		progressWriter.fail(err)
+		return
+	}
+	defer newFile.Close()
+
+	if err := newFile.Truncate(file.Size); err != nil {
+		progressWriter.fail(err)
+		return
+	}
+
+	chunks := s.fetchChunks(progressWriter.context(), sources[0], file.Hash)
+	ranges := splitRanges(file.Size, swarmRangeSize)
+	if resume && chunks != nil {
+		ranges = missingRanges(newFile, chunks, file.Size, progressWriter)
+	}
+	queue := make(chan byteRange, len(splitRanges(file.Size, chunkSize))+1)
+	for _, r := range ranges {
+		queue <- r
+	}
+
+	// remaining counts ranges not yet written; done is closed when it reaches zero
+	var workers sync.WaitGroup
+	var pendingMu sync.Mutex
+	remaining := len(ranges)
+	done := make(chan struct{})
+	if remaining == 0 {
+		close(done)
+	}
+	finishRange := func(requeued int) {
+		pendingMu.Lock()
+		defer pendingMu.Unlock()
+		remaining += requeued - 1
+		if remaining == 0 {
+			close(done)
+		}
+	}
+	errs := make(chan error, len(sources))
+	for _, source := range sources {
+		workers.Add(1)
+		go func(source Device) {
+			defer workers.Done()
+			for r := range queue {
+				if progressWriter.stopped() {
+					return
+				}
+				var verifier *chunkVerifier
+				if chunks != nil {
+					verifier = newChunkVerifier(chunks, file.Size, r.offset)
+				}
+				err := s.fetchRange(source, file, r, newFile, progressWriter, verifier)
+				if err != nil {
+					queue <- r
+				} else if bad := verifier.Finish(); len(bad) > 0 {
+					finishRange(len(bad))
+					for _, b := range bad {
+						progressWriter.add(-b.length)
+						queue <- b
+					}
+					err = fmt.Errorf("sent %d corrupt chunks", len(bad))
+				}
+				if err != nil {
+					errs <- fmt.Errorf("%s %s: %s", source.Name, source.Ip, err.Error())
+					s.log(LevelWarn, "swarm", "dropping source", logPeer(source), logHash(file.Hash), logErr(err))
+					return
+				}
+				finishRange(0)
+			}
+		}(source)
+	}
+
+	failed := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(failed)
+	}()
+
+	select {
+	case <-done:
+		close(queue)
+		workers.Wait()
+	case <-failed:
+		close(errs)
+		msgs := make([]string, 0)
+		for err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		progressWriter.fail(fmt.Errorf("all sources failed: %s", strings.Join(msgs, "; ")))
+		return
+	}
+
+	s.verifyDownload(sources[0], file, progressWriter)
+}
+
+func (s *SocketServer) fetchRange(d Device, file File, r byteRange, f *os.File, progressWriter *ProgressWriter, verifier *chunkVerifier) error {
+	ticket, remoteCompression, err := s.requestTicket(progressWriter.context(), d, file.Hash)
+	if err != nil {
+		return err
+	}
+
+	connection, err := s.dial(progressWriter.context(), d)
+	if err != nil {
+		return err
+	}
+	defer connection.Close()
+	defer progressWriter.watch(connection)()
+
+	compression := s.compressionFor(file, remoteCompression)
+	err = writeRequest(connection, fileRequest{Hash: file.Hash, Offset: r.offset, Length: r.length, Ticket: ticket, Compression: compression})
+	if err != nil {
+		return err
+	}
+
+	reader, err := decompressReader(s.bandwidth.reader(connection, d.Ip), compression)
+	if err != nil {
+		return err
+	}
+
+	writers := []io.Writer{&offsetWriter{file: f, offset: r.offset}, progressWriter}
+	if verifier != nil {
+		writers = append(writers, verifier)
+	}
+
+	n, err := io.Copy(io.MultiWriter(writers...), io.LimitReader(reader, r.length))
+	if err == nil && n != r.length {
+		err = fmt.Errorf("short range at offset %d, want %d bytes have %d", r.offset, r.length, n)
+	}
+	if err != nil {
+		progressWriter.add(-n)
+		return err
+	}
+	return nil
+}
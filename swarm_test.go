@@ -0,0 +1,51 @@
+package aero
+
+import (
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMissingRangesKeepsVerifiedChunks(t *testing.T) {
+	size := int64(9*chunkSize + 100)
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(t.TempDir(), "source.bin")
+	if err := os.WriteFile(source, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	chunks := NewFile(source).Chunks
+
+	// chunk 1 is corrupted, chunks 6 and up were never written
+	partial := append([]byte{}, data...)
+	partial[chunkSize+5] ^= 0xff
+	for i := 6 * chunkSize; i < int(size); i++ {
+		partial[i] = 0
+	}
+	target := filepath.Join(t.TempDir(), "target.bin")
+	if err := os.WriteFile(target, partial, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	progressWriter := &ProgressWriter{FileSize: size}
+	have := missingRanges(f, chunks, size, progressWriter)
+	want := []byteRange{
+		{offset: chunkSize, length: chunkSize},
+		{offset: 6 * chunkSize, length: 3*chunkSize + 100},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+	if progressWriter.Received != 5*chunkSize {
+		t.Fatalf("want %d bytes kept, have %d", 5*chunkSize, progressWriter.Received)
+	}
+}
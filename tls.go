@@ -0,0 +1,50 @@
+package aero
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+type TLSConfig struct {
+	CertFile          string
+	KeyFile           string
+	CAFile            string
+	ServerName        string
+	RequireClientCert bool
+}
+
+func (aero *Aero) SetTLS(conf TLSConfig) error {
+	cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	ca, err := os.ReadFile(conf.CAFile)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return fmt.Errorf("no certificates found in %s", conf.CAFile)
+	}
+
+	aero.serverTLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		MinVersion:   tls.VersionTLS12,
+	}
+	if conf.RequireClientCert {
+		aero.serverTLS.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	aero.clientTLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ServerName:   conf.ServerName,
+		MinVersion:   tls.VersionTLS12,
+	}
+	return nil
+}
@@ -0,0 +1,366 @@
+package aero
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dhamith93/aero/internal/api"
+	"github.com/dhamith93/aero/internal/auth"
+)
+
+const (
+	offerTimeout  = 2 * time.Minute
+	offerLifetime = time.Minute
+)
+
+type OfferHandler func(from Device, file File) (accept bool, destination string)
+
+type offer struct {
+	from    Device
+	file    File
+	dest    string
+	expires time.Time
+	taken   bool
+}
+
+func (aero *Aero) SetOfferHandler(handler OfferHandler) {
+	aero.configMu.Lock()
+	defer aero.configMu.Unlock()
+	aero.offerHandler = handler
+}
+
+func (aero *Aero) Send(d Device, f File) (int, error) {
+	return aero.SendContext(context.Background(), d, f)
+}
+
+func (aero *Aero) SendContext(ctx context.Context, d Device, f File) (int, error) {
+	c, err := aero.dial(d)
+	if err != nil {
+		return 0, err
+	}
+	offerCtx, cancel := context.WithTimeout(aero.authContext(ctx), offerTimeout)
+	defer cancel()
+
+	self := aero.registry.getSelf()
+	from := GenerateAPIDeviceFromDevice(&self)
+	from.Files = nil
+	resp, err := c.Offer(offerCtx, &api.FileOffer{From: from, File: GenerateAPIFileFromFile(&f)})
+	if err != nil {
+		return 0, err
+	}
+	if !resp.Accepted {
+		return 0, errors.New(resp.Error)
+	}
+	return aero.SocketServer.startUpload(ctx, d, f, resp.Ticket, resp.Compression), nil
+}
+
+func (aero *Aero) acceptOffer(o *api.FileOffer, requester string) (string, error) {
+	aero.configMu.RLock()
+	handler := aero.offerHandler
+	aero.configMu.RUnlock()
+	if handler == nil {
+		return "", fmt.Errorf("device does not accept offers")
+	}
+	from := *GenerateDeviceFromAPIDevice(o.From)
+	file := *GenerateFileFromAPIFile(o.File)
+	file.Name = safeName(file.Name)
+	accept, dest := handler(from, file)
+	if !accept {
+		return "", fmt.Errorf("offer rejected")
+	}
+	if len(dest) == 0 {
+		dest = aero.SocketServer.defaultTarget(file.Name)
+	}
+
+	ticket, err := auth.GenerateTicket(aero.key, file.Hash, requester)
+	if err != nil {
+		return "", err
+	}
+	err = aero.SocketServer.expectUpload(ticket, offer{from: from, file: file, dest: dest, expires: time.Now().Add(offerLifetime)})
+	if err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+// the destination stays reserved from acceptance until the upload is received
+func (s *SocketServer) expectUpload(ticket string, o offer) error {
+	s.reserveMu.Lock()
+	defer s.reserveMu.Unlock()
+	dest, err := s.resolveTarget(o.dest)
+	if err != nil {
+		return err
+	}
+	o.dest = dest
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.offers == nil {
+		s.offers = make(map[string]offer)
+	}
+	for t, pending := range s.offers {
+		if !pending.taken && time.Now().After(pending.expires) {
+			delete(s.offers, t)
+		}
+	}
+	s.offers[ticket] = o
+	return nil
+}
+
+func (s *SocketServer) takeUpload(ticket string) (offer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.offers[ticket]
+	if !ok || o.taken {
+		return offer{}, false
+	}
+	o.taken = true
+	s.offers[ticket] = o
+	return o, true
+}
+
+func (s *SocketServer) releaseUpload(ticket string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.offers, ticket)
+}
+
+func (s *SocketServer) offered(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, pending := range s.offers {
+		if pending.dest == path && (pending.taken || time.Now().Before(pending.expires)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SocketServer) Upload(d Device, f File, ticket string) int {
+	return s.UploadContext(context.Background(), d, f, ticket)
+}
+
+func (s *SocketServer) UploadContext(ctx context.Context, d Device, f File, ticket string) int {
+	return s.startUpload(ctx, d, f, ticket, false)
+}
+
+func (aero *Aero) UploadStatus(uploadId int) (DownloadStatus, error) {
+	return aero.SocketServer.UploadStatus(uploadId)
+}
+
+func (aero *Aero) ListUploads() []DownloadStatus {
+	return aero.SocketServer.ListUploads()
+}
+
+func (s *SocketServer) UploadStatus(uploadId int) (DownloadStatus, error) {
+	s.uploadsMu.Lock()
+	progressWriter, ok := s.uploads[uploadId]
+	s.uploadsMu.Unlock()
+	if !ok {
+		return DownloadStatus{}, fmt.Errorf("upload %d not found", uploadId)
+	}
+	return progressWriter.status(), nil
+}
+
+func (s *SocketServer) ListUploads() []DownloadStatus {
+	s.uploadsMu.Lock()
+	out := make([]DownloadStatus, 0, len(s.uploads))
+	for _, progressWriter := range s.uploads {
+		out = append(out, progressWriter.status())
+	}
+	s.uploadsMu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Id < out[j].Id })
+	return out
+}
+
+func (s *SocketServer) startUpload(ctx context.Context, d Device, f File, ticket string, remoteCompression bool) int {
+	target := d
+	target.Files = []File{f}
+	progressWriter := &ProgressWriter{FileSize: f.Size, device: target, path: f.Path, ctx: ctx}
+
+	s.uploadsMu.Lock()
+	if s.uploads == nil {
+		s.uploads = make(map[int]*ProgressWriter)
+	}
+	s.lastUpload++
+	progressWriter.id = s.lastUpload
+	s.uploads[progressWriter.id] = progressWriter
+	s.uploadsMu.Unlock()
+
+	progressWriter.begin()
+	go s.upload(d, f, ticket, remoteCompression, progressWriter)
+	return progressWriter.id
+}
+
+func (s *SocketServer) upload(d Device, f File, ticket string, remoteCompression bool, progressWriter *ProgressWriter) {
+	finish := s.trackProgress(ProgressEvent{Kind: UploadTransfer, Id: progressWriter.id, Name: f.Name, Hash: f.Hash, Device: d}, progressWriter)
+	state := Failed
+	started := time.Now()
+	defer func() {
+		if state == Failed && progressWriter.context().Err() != nil {
+			state = Cancelled
+		}
+		progressWriter.setState(state)
+		finish(state)
+		entry := JournalEntry{Kind: UploadTransfer, State: state, File: f, Device: d, Path: f.Path, Started: started}
+		if _, _, err := progressWriter.snapshot(); err != nil {
+			entry.Error = err.Error()
+			if state == Failed {
+				s.log(LevelError, "upload", "cannot send "+f.Name, logPeer(d), logHash(f.Hash), logErr(err))
+			}
+		}
+		s.record(entry)
+	}()
+
+	file, err := os.Open(strings.TrimSpace(f.Path))
+	if err != nil {
+		progressWriter.fail(err)
+		return
+	}
+	defer file.Close()
+
+	s.bandwidth.acquireUpload()
+	defer s.bandwidth.releaseUpload()
+
+	connection, err := s.dial(progressWriter.context(), d)
+	if err != nil {
+		progressWriter.fail(err)
+		return
+	}
+	defer connection.Close()
+	if !s.track(connection, d) {
+		progressWriter.fail(fmt.Errorf("socket server is shutting down"))
+		return
+	}
+	defer s.untrack(connection)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-progressWriter.context().Done():
+			connection.Close()
+		case <-done:
+		}
+	}()
+
+	compression := s.compressionFor(f, remoteCompression)
+	err = writeRequest(connection, fileRequest{Action: actionUpload, Hash: f.Hash, Length: f.Size, Ticket: ticket, Compression: compression})
+	if err != nil {
+		progressWriter.fail(err)
+		return
+	}
+
+	out, err := compressWriter(s.bandwidth.writer(connection, d.Ip), compression)
+	if err != nil {
+		progressWriter.fail(err)
+		return
+	}
+
+	_, err = io.Copy(out, io.TeeReader(file, progressWriter))
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		progressWriter.fail(err)
+		return
+	}
+
+	reply, err := bufio.NewReader(connection).ReadString('\n')
+	if err != nil {
+		progressWriter.fail(err)
+		return
+	}
+	if reply = strings.TrimSpace(reply); reply != "ok" {
+		progressWriter.fail(errors.New(reply))
+		return
+	}
+
+	s.log(LevelInfo, "upload", "sent file "+f.Name, logPeer(d), logHash(f.Hash))
+	progressWriter.complete()
+	state = Done
+}
+
+func (s *SocketServer) receiveUpload(connection net.Conn, reader *bufio.Reader, request fileRequest) {
+	o, ok := s.takeUpload(request.Ticket)
+	if ok {
+		defer s.releaseUpload(request.Ticket)
+	}
+	if !ok || o.file.Hash != request.Hash {
+		s.log(LevelError, "receive_file", "no accepted offer", logHash(request.Hash))
+		fmt.Fprintln(connection, "offer not accepted")
+		return
+	}
+	if !s.acceptsCompression(request.Compression) {
+		s.log(LevelError, "receive_file", "refused "+request.Compression+" compression while compression is disabled", logPeer(o.from), logHash(request.Hash))
+		fmt.Fprintln(connection, "compression not accepted")
+		return
+	}
+
+	target := o.dest
+	if s.downloadOptions().TempFile {
+		target += partialSuffix
+	}
+	newFile, err := createTarget(target, true)
+	if err != nil {
+		s.log(LevelError, "receive_file", "cannot receive "+o.file.Name, logPeer(o.from), logHash(o.file.Hash), logErr(err))
+		fmt.Fprintln(connection, err.Error())
+		return
+	}
+
+	progressWriter := &ProgressWriter{FileSize: o.file.Size, device: o.from}
+	finish := s.trackProgress(ProgressEvent{Kind: DownloadTransfer, Name: o.file.Name, Hash: o.file.Hash, Device: o.from}, progressWriter)
+	state := Failed
+	started := time.Now()
+	defer func() {
+		finish(state)
+		entry := JournalEntry{Kind: DownloadTransfer, State: state, File: o.file, Device: o.from, Path: o.dest, Started: started}
+		if _, _, err := progressWriter.snapshot(); err != nil {
+			entry.Error = err.Error()
+		}
+		s.record(entry)
+	}()
+
+	in, err := decompressReader(s.bandwidth.reader(reader, o.from.Ip), request.Compression)
+	if err == nil {
+		_, err = io.CopyN(io.MultiWriter(newFile, progressWriter), in, o.file.Size)
+	}
+	newFile.Close()
+	if err != nil {
+		s.log(LevelError, "receive_file", "cannot receive "+o.file.Name, logPeer(o.from), logHash(o.file.Hash), logErr(err))
+		progressWriter.fail(err)
+		fmt.Fprintln(connection, err.Error())
+		return
+	}
+
+	createdFile := NewFile(target)
+	if createdFile.Hash != o.file.Hash {
+		os.Remove(target)
+		err := fmt.Errorf("file transfer failed due to hash mismatch. want %s have %s", o.file.Hash, createdFile.Hash)
+		s.log(LevelError, "receive_file", "cannot receive "+o.file.Name, logPeer(o.from), logHash(o.file.Hash), logErr(err))
+		progressWriter.fail(err)
+		fmt.Fprintln(connection, err.Error())
+		return
+	}
+
+	if target != o.dest {
+		if err := os.Rename(target, o.dest); err != nil {
+			s.log(LevelError, "receive_file", "cannot receive "+o.file.Name, logPeer(o.from), logHash(o.file.Hash), logErr(err))
+			progressWriter.fail(err)
+			fmt.Fprintln(connection, err.Error())
+			return
+		}
+	}
+
+	s.log(LevelInfo, "receive_file", "received file "+filepath.Base(o.dest), logPeer(o.from), logHash(o.file.Hash))
+	state = Done
+	fmt.Fprintln(connection, "ok")
+}
@@ -0,0 +1,70 @@
+package aero
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	watchDebounce = 200 * time.Millisecond
+	watchResync   = 2 * time.Second
+)
+
+func (aero *Aero) sharedPaths() map[string]bool {
+	paths := make(map[string]bool)
+	for _, f := range aero.registry.getSelf().Files {
+		paths[filepath.Clean(f.Path)] = true
+	}
+	return paths
+}
+
+func (aero *Aero) applyFileChanges(ctx context.Context, paths map[string]bool) {
+	// rehash outside the lock, nil marks a file that is gone
+	rehashed := make(map[string]*File)
+	for path := range aero.sharedPaths() {
+		if !paths[path] {
+			continue
+		}
+		rehashed[path] = nil
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if updated := NewFile(path); len(updated.Hash) > 0 {
+			rehashed[path] = &updated
+		}
+	}
+	if len(rehashed) == 0 {
+		return
+	}
+
+	changed := false
+	self, _ := aero.registry.updateSelf(func(self *Device) error {
+		files := make([]File, 0, len(self.Files))
+		for _, f := range self.Files {
+			updated, ok := rehashed[filepath.Clean(f.Path)]
+			if !ok {
+				files = append(files, f)
+				continue
+			}
+			if updated == nil {
+				changed = true
+				continue
+			}
+			file := *updated
+			file.Path = f.Path
+			file.RelPath = f.RelPath
+			if file.Hash != f.Hash || file.Size != f.Size {
+				changed = true
+			}
+			files = append(files, file)
+		}
+		self.Files = files
+		return nil
+	})
+
+	if changed {
+		aero.SendRefreshContext(ctx, self)
+	}
+}
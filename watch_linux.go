@@ -0,0 +1,85 @@
+package aero
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM | syscall.IN_DELETE | syscall.IN_ATTRIB
+
+func (aero *Aero) WatchFiles(ctx context.Context) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return err
+	}
+	f := os.NewFile(uintptr(fd), "inotify")
+	defer f.Close()
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+
+	watched := make(map[int]string)
+	syncWatches(fd, watched, aero.sharedPaths())
+
+	buffer := make([]byte, 64*1024)
+	pending := make(map[string]bool)
+	for {
+		timeout := watchResync
+		if len(pending) > 0 {
+			timeout = watchDebounce
+		}
+		f.SetReadDeadline(time.Now().Add(timeout))
+		n, err := f.Read(buffer)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			if len(pending) > 0 {
+				aero.applyFileChanges(ctx, pending)
+				pending = make(map[string]bool)
+			}
+			syncWatches(fd, watched, aero.sharedPaths())
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buffer[nameStart:nameStart+int(event.Len)]), "\x00")
+			if dir, ok := watched[int(event.Wd)]; ok && len(name) > 0 {
+				pending[filepath.Join(dir, name)] = true
+			}
+			offset = nameStart + int(event.Len)
+		}
+	}
+}
+
+func syncWatches(fd int, watched map[int]string, paths map[string]bool) {
+	dirs := make(map[string]bool)
+	for path := range paths {
+		dirs[filepath.Dir(path)] = true
+	}
+	for wd, dir := range watched {
+		if !dirs[dir] {
+			syscall.InotifyRmWatch(fd, uint32(wd))
+			delete(watched, wd)
+		}
+		delete(dirs, dir)
+	}
+	for dir := range dirs {
+		wd, err := syscall.InotifyAddWatch(fd, dir, inotifyMask)
+		if err == nil {
+			watched[wd] = dir
+		}
+	}
+}
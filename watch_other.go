@@ -0,0 +1,44 @@
+//go:build !linux
+
+package aero
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+func (aero *Aero) WatchFiles(ctx context.Context) error {
+	states := make(map[string]fileState)
+	ticker := time.NewTicker(watchResync)
+	defer ticker.Stop()
+	for {
+		changed := make(map[string]bool)
+		for path := range aero.sharedPaths() {
+			info, err := os.Stat(path)
+			if err != nil {
+				changed[path] = true
+				continue
+			}
+			state := fileState{size: info.Size(), modTime: info.ModTime()}
+			if previous, ok := states[path]; ok && previous != state {
+				changed[path] = true
+			}
+			states[path] = state
+		}
+		if len(changed) > 0 {
+			aero.applyFileChanges(ctx, changed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}